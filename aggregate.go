@@ -0,0 +1,685 @@
+package weheat
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// AggregateLogs groups raw logs into HeatPumpLogView buckets the way
+// GetLogs does: one UTC bucket per interval that holds logs, with
+// duration-weighted averages, minima and maxima, and counters holding the
+// seconds spent in each state. Weeks start on Monday. Unknown intervals
+// yield nil.
+func AggregateLogs(logs []RawHeatPumpLog, interval LogInterval) []HeatPumpLogView {
+	return aggregateLogs(logs, interval, time.UTC)
+}
+
+// AggregateEnergyLogs integrates the power readings of raw logs into UTC
+// EnergyView buckets the way GetEnergyLogs does. Energy is in kWh, split by
+// the state the pump was in, and average powers are in watts over the whole
+// bucket. Intervals other than the EnergyInterval constants yield nil.
+func AggregateEnergyLogs(logs []RawHeatPumpLog, interval EnergyInterval) []EnergyView {
+	return aggregateEnergyLogs(logs, interval, time.UTC)
+}
+
+// AggregateRawLogs is AggregateLogs with day and longer buckets starting at
+// midnight in loc (UTC when nil), so local and API aggregates can be used
+// interchangeably. TimeCoveredInInterval is the number of seconds backed by
+// samples: gaps in the data show up as a shortfall against the bucket length
+// rather than skewing the averages.
+func AggregateRawLogs(logs []RawHeatPumpLog, interval LogInterval, loc *time.Location) []HeatPumpLogView {
+	return aggregateLogs(logs, interval, loc)
+}
+
+func aggregateLogs(logs []RawHeatPumpLog, interval LogInterval, loc *time.Location) []HeatPumpLogView {
+	buckets, ok := bucketRawLogs(logs, interval, loc)
+	if !ok {
+		return nil
+	}
+	views := make([]HeatPumpLogView, 0, len(buckets))
+	for _, bucket := range buckets {
+		views = append(views, bucket.logView(interval))
+	}
+	return views
+}
+
+func aggregateEnergyLogs(logs []RawHeatPumpLog, interval EnergyInterval, loc *time.Location) []EnergyView {
+	switch interval {
+	case EnergyIntervalHour, EnergyIntervalDay, EnergyIntervalWeek, EnergyIntervalMonth, EnergyIntervalYear:
+	default:
+		return nil
+	}
+	buckets, _ := bucketRawLogs(logs, LogInterval(interval), loc)
+	views := make([]EnergyView, 0, len(buckets))
+	for _, bucket := range buckets {
+		views = append(views, bucket.energyView(string(interval)))
+	}
+	return views
+}
+
+// logBucket holds the logs of one aggregation bucket and how many seconds
+// each of them accounts for.
+type logBucket struct {
+	start   time.Time
+	end     time.Time
+	logs    []RawHeatPumpLog
+	seconds []float64
+}
+
+func bucketRawLogs(logs []RawHeatPumpLog, interval LogInterval, loc *time.Location) ([]logBucket, bool) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if _, ok := bucketStart(time.Time{}, interval, loc); !ok {
+		return nil, false
+	}
+	sorted := sortUniqueLogs(slices.Clone(logs), time.Time{})
+
+	var buckets []logBucket
+	for i, log := range sorted {
+		start, _ := bucketStart(log.Timestamp, interval, loc)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].start.Equal(start) {
+			buckets = append(buckets, logBucket{start: start, end: bucketEnd(start, interval)})
+		}
+		b := &buckets[len(buckets)-1]
+		// A sample covers its Interval, cut short by the next sample and
+		// the bucket end, so gaps in the data count as uncovered time.
+		covered := time.Duration(max(log.Interval, 0)) * time.Second
+		if i+1 < len(sorted) {
+			covered = min(covered, sorted[i+1].Timestamp.Sub(log.Timestamp))
+		}
+		covered = min(covered, b.end.Sub(log.Timestamp))
+		b.logs = append(b.logs, log)
+		b.seconds = append(b.seconds, covered.Seconds())
+	}
+	return buckets, true
+}
+
+// bucketStart returns the start of the bucket holding t. Only day and longer
+// buckets follow the calendar in loc.
+func bucketStart(t time.Time, interval LogInterval, loc *time.Location) (time.Time, bool) {
+	t = t.In(loc)
+	// Fixed-length buckets follow absolute time, so a repeated or skipped
+	// local hour around a DST change neither merges nor splits them.
+	if step := logIntervalDuration(interval); step > 0 {
+		return t.Truncate(step), true
+	}
+	year, month, day := t.Date()
+	switch interval {
+	case LogIntervalDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, loc), true
+	case LogIntervalWeek:
+		monday := day - (int(t.Weekday())+6)%7
+		return time.Date(year, month, monday, 0, 0, 0, 0, loc), true
+	case LogIntervalMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc), true
+	case LogIntervalYear:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, loc), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// bucketEnd returns the end of the bucket starting at start.
+func bucketEnd(start time.Time, interval LogInterval) time.Time {
+	if step := logIntervalDuration(interval); step > 0 {
+		return start.Add(step)
+	}
+	switch interval {
+	case LogIntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case LogIntervalMonth:
+		return start.AddDate(0, 1, 0)
+	case LogIntervalYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// pumpMode is the operating mode a raw log's state code falls into.
+type pumpMode int
+
+const (
+	modeOther pumpMode = iota
+	modeStandby
+	modeHeating
+	modeCooling
+	modeDHW
+	modeLegionella
+	modeManualControl
+	modeHeatingDefrost
+	modeDHWDefrost
+)
+
+// logMode classifies a raw log. Defrosts are attributed to DHW when the DHW
+// valve is open and to heating otherwise.
+func logMode(log *RawHeatPumpLog) pumpMode {
+	if log.State == nil {
+		return modeOther
+	}
+	state := ParseHeatPumpState(*log.State)
+	if state == nil {
+		return modeOther
+	}
+	switch *state {
+	case HeatPumpStateStandby:
+		return modeStandby
+	case HeatPumpStateHeating:
+		return modeHeating
+	case HeatPumpStateCooling:
+		return modeCooling
+	case HeatPumpStateDHW:
+		return modeDHW
+	case HeatPumpStateLegionella:
+		return modeLegionella
+	case HeatPumpStateManualControl:
+		return modeManualControl
+	case HeatPumpStateDefrosting:
+		if isTrue(log.ControlBridgeStatusDecodedDHWValve) {
+			return modeDHWDefrost
+		}
+		return modeHeatingDefrost
+	default:
+		return modeOther
+	}
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
+
+// Water pump feedback ranges, following the Grundfos PWM feedback signal.
+type flowState int
+
+const (
+	flowStandby flowState = iota
+	flowStandbyNoPWM
+	flowPumping
+	flowPumpingNoPWM
+	flowSuboptimalRunning
+	flowStoppedMomentarily
+	flowMotorBlocked
+	flowStoppedPermanentDamage
+)
+
+// pumpFlowState classifies a pump's feedback signal; requested is the duty
+// cycle the controller asked for, with zero meaning no PWM input.
+func pumpFlowState(feedback int, requested *int) flowState {
+	noPWM := requested != nil && *requested == 0
+	switch {
+	case feedback <= 5:
+		if noPWM {
+			return flowStandbyNoPWM
+		}
+		return flowStandby
+	case feedback <= 75:
+		if noPWM {
+			return flowPumpingNoPWM
+		}
+		return flowPumping
+	case feedback <= 80:
+		return flowSuboptimalRunning
+	case feedback <= 85:
+		return flowStoppedMomentarily
+	case feedback <= 90:
+		return flowMotorBlocked
+	case feedback <= 95:
+		return flowStoppedPermanentDamage
+	default:
+		if noPWM {
+			return flowStandbyNoPWM
+		}
+		return flowStandby
+	}
+}
+
+type logCounter struct {
+	field func(*HeatPumpLogView) **int
+	match func(*RawHeatPumpLog, pumpMode) bool
+}
+
+func modeIs(modes ...pumpMode) func(*RawHeatPumpLog, pumpMode) bool {
+	return func(_ *RawHeatPumpLog, mode pumpMode) bool {
+		return slices.Contains(modes, mode)
+	}
+}
+
+func flagSet(value func(*RawHeatPumpLog) *bool) func(*RawHeatPumpLog, pumpMode) bool {
+	return func(log *RawHeatPumpLog, _ pumpMode) bool {
+		return isTrue(value(log))
+	}
+}
+
+func intIs(value func(*RawHeatPumpLog) *int, want int) func(*RawHeatPumpLog, pumpMode) bool {
+	return func(log *RawHeatPumpLog, _ pumpMode) bool {
+		v := value(log)
+		return v != nil && *v == want
+	}
+}
+
+func flowIs(feedback, requested func(*RawHeatPumpLog) *int, want flowState) func(*RawHeatPumpLog, pumpMode) bool {
+	return func(log *RawHeatPumpLog, _ pumpMode) bool {
+		v := feedback(log)
+		return v != nil && pumpFlowState(*v, requested(log)) == want
+	}
+}
+
+func dutyCyclePumping(value func(*RawHeatPumpLog) *int, pumping bool) func(*RawHeatPumpLog, pumpMode) bool {
+	return func(log *RawHeatPumpLog, _ pumpMode) bool {
+		v := value(log)
+		return v != nil && (*v > 5) == pumping
+	}
+}
+
+var (
+	chFlow     = func(l *RawHeatPumpLog) *int { return l.CentralHeatingFlow }
+	chDuty     = func(l *RawHeatPumpLog) *int { return l.CentralHeatingPWMRequestedDutyCycle }
+	dhwFlow    = func(l *RawHeatPumpLog) *int { return l.DHWFlow }
+	dhwDuty    = func(l *RawHeatPumpLog) *int { return l.DHWPWMRequestedDutyCycle }
+	rpmLimiter = func(l *RawHeatPumpLog) *int { return l.RPMLimiterType }
+	onOffStat  = func(l *RawHeatPumpLog) *int { return l.OnOffThermostatState }
+)
+
+// logCounters lists the seconds-in-state counters of HeatPumpLogView.
+var logCounters = []logCounter{
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateStandby }, modeIs(modeStandby)},
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateHeating }, modeIs(modeHeating)},
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateCooling }, modeIs(modeCooling)},
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateDHW }, modeIs(modeDHW)},
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateLegionella }, modeIs(modeLegionella)},
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateManualControl }, modeIs(modeManualControl)},
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateDHWDefrost }, modeIs(modeDHWDefrost)},
+	{func(v *HeatPumpLogView) **int { return &v.HeatPumpStateHeatingDefrost }, modeIs(modeHeatingDefrost)},
+
+	{func(v *HeatPumpLogView) **int { return &v.ControlBridgeStatusWaterPump }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ControlBridgeStatusDecodedWaterPump })},
+	{func(v *HeatPumpLogView) **int { return &v.ControlBridgeStatusGasBoiler }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ControlBridgeStatusDecodedGasBoiler })},
+	{func(v *HeatPumpLogView) **int { return &v.ControlBridgeStatusElectricHeater }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ControlBridgeStatusDecodedElectricHeater })},
+	{func(v *HeatPumpLogView) **int { return &v.ControlBridgeStatusWaterPump2 }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ControlBridgeStatusDecodedWaterPump2 })},
+	{func(v *HeatPumpLogView) **int { return &v.ControlBridgeStatusDHWValve }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ControlBridgeStatusDecodedDHWValve })},
+
+	{func(v *HeatPumpLogView) **int { return &v.ThermostatStateOff }, intIs(onOffStat, 0)},
+	{func(v *HeatPumpLogView) **int { return &v.ThermostatStateOn }, intIs(onOffStat, 1)},
+
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStateStandby }, flowIs(chFlow, chDuty, flowStandby)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStateStandbyNoPWM }, flowIs(chFlow, chDuty, flowStandbyNoPWM)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStateMotorBlocked }, flowIs(chFlow, chDuty, flowMotorBlocked)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStatePumping }, flowIs(chFlow, chDuty, flowPumping)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStatePumpingNoPWM }, flowIs(chFlow, chDuty, flowPumpingNoPWM)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStateSuboptimalRunning }, flowIs(chFlow, chDuty, flowSuboptimalRunning)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStateStoppedMomentarily }, flowIs(chFlow, chDuty, flowStoppedMomentarily)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingFlowStateStoppedPermanentDamage }, flowIs(chFlow, chDuty, flowStoppedPermanentDamage)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStateStandby }, flowIs(dhwFlow, dhwDuty, flowStandby)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStateStandbyNoPWM }, flowIs(dhwFlow, dhwDuty, flowStandbyNoPWM)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStateMotorBlocked }, flowIs(dhwFlow, dhwDuty, flowMotorBlocked)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStatePumping }, flowIs(dhwFlow, dhwDuty, flowPumping)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStatePumpingNoPWM }, flowIs(dhwFlow, dhwDuty, flowPumpingNoPWM)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStateSuboptimalRunning }, flowIs(dhwFlow, dhwDuty, flowSuboptimalRunning)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStateStoppedMomentarily }, flowIs(dhwFlow, dhwDuty, flowStoppedMomentarily)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWFlowStateStoppedPermanentDamage }, flowIs(dhwFlow, dhwDuty, flowStoppedPermanentDamage)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingPWMRequestedDutyCycleStateStandby }, dutyCyclePumping(chDuty, false)},
+	{func(v *HeatPumpLogView) **int { return &v.CentralHeatingPWMRequestedDutyCycleStatePumping }, dutyCyclePumping(chDuty, true)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWPWMRequestedDutyCycleStateStandby }, dutyCyclePumping(dhwDuty, false)},
+	{func(v *HeatPumpLogView) **int { return &v.DHWPWMRequestedDutyCycleStatePumping }, dutyCyclePumping(dhwDuty, true)},
+
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterNoLimit }, intIs(rpmLimiter, 0)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterPowerLimit }, intIs(rpmLimiter, 1)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterDefrost }, intIs(rpmLimiter, 2)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterSilentHours }, intIs(rpmLimiter, 3)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterHPControl }, intIs(rpmLimiter, 4)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterPressure }, intIs(rpmLimiter, 5)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterWaterOut }, intIs(rpmLimiter, 6)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterEnvelope }, intIs(rpmLimiter, 7)},
+	{func(v *HeatPumpLogView) **int { return &v.RPMLimiterHouseIn }, intIs(rpmLimiter, 8)},
+
+	{func(v *HeatPumpLogView) **int { return &v.DTCNone }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcNone })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCContinue }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcContinue })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCCompressorOff }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcCompressorOff })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCDefrostForbidden }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcDefrostForbidden })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCRequestService }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcRequestService })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCUseHeatingCurve }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcUseHeatingCurve })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCDHWForbidden }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcDHWForbidden })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCError }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcError })},
+	{func(v *HeatPumpLogView) **int { return &v.DTCInactive }, flagSet(func(l *RawHeatPumpLog) *bool { return l.ErrorDecodedDtcInactive })},
+}
+
+type logStat struct {
+	value func(*RawHeatPumpLog, pumpMode) *float64
+	field func(*HeatPumpLogView) (avg, min, max **float64)
+}
+
+func float(value func(*RawHeatPumpLog) *float64) func(*RawHeatPumpLog, pumpMode) *float64 {
+	return func(log *RawHeatPumpLog, _ pumpMode) *float64 {
+		return value(log)
+	}
+}
+
+func integer(value func(*RawHeatPumpLog) *int) func(*RawHeatPumpLog, pumpMode) *float64 {
+	return func(log *RawHeatPumpLog, _ pumpMode) *float64 {
+		return intToFloat(value(log))
+	}
+}
+
+// powerIn and powerOut read the mass-flow power while in one of modes.
+func powerIn(modes ...pumpMode) func(*RawHeatPumpLog, pumpMode) *float64 {
+	return func(log *RawHeatPumpLog, mode pumpMode) *float64 {
+		if !slices.Contains(modes, mode) {
+			return nil
+		}
+		return intToFloat(log.CMMassPowerIn)
+	}
+}
+
+func powerOut(modes ...pumpMode) func(*RawHeatPumpLog, pumpMode) *float64 {
+	return func(log *RawHeatPumpLog, mode pumpMode) *float64 {
+		if !slices.Contains(modes, mode) {
+			return nil
+		}
+		return intToFloat(log.CMMassPowerOut)
+	}
+}
+
+// logStats lists the average/min/max fields of HeatPumpLogView.
+var logStats = []logStat{
+	{float(func(l *RawHeatPumpLog) *float64 { return l.T1 }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.T1Average, &v.T1Min, &v.T1Max
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.T2 }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.T2Average, &v.T2Min, &v.T2Max
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TAirIn }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TAirInAverage, &v.TAirInMin, &v.TAirInMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TAirOut }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TAirOutAverage, &v.TAirOutMin, &v.TAirOutMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TWaterIn }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TWaterInAverage, &v.TWaterInMin, &v.TWaterInMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TWaterOut }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TWaterOutAverage, &v.TWaterOutMin, &v.TWaterOutMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TWaterHouseIn }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TWaterHouseInAverage, &v.TWaterHouseInMin, &v.TWaterHouseInMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TRoom }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TRoomAverage, &v.TRoomMin, &v.TRoomMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TRoomTarget }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TRoomTargetAverage, &v.TRoomTargetMin, &v.TRoomTargetMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TThermostatSetpoint }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TThermostatSetpointAverage, &v.TThermostatSetpointMin, &v.TThermostatSetpointMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.OTBoilerFeedTemperature }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.OTBoilerFeedTemperatureAverage, &v.OTBoilerFeedTemperatureMin, &v.OTBoilerFeedTemperatureMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.OTBoilerReturnTemperature }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.OTBoilerReturnTemperatureAverage, &v.OTBoilerReturnTemperatureMin, &v.OTBoilerReturnTemperatureMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.RPM }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.RPMAverage, &v.RPMMin, &v.RPMMax
+	}},
+	{integer(chFlow), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CentralHeatingFlowAverage, &v.CentralHeatingFlowMin, &v.CentralHeatingFlowMax
+	}},
+	{integer(dhwFlow), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.DHWFlowAverage, &v.DHWFlowMin, &v.DHWFlowMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.RPMLimiter }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.RPMLimiterAverage, &v.RPMLimiterMin, &v.RPMLimiterMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.PCompressorIn }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.PCompressorInAverage, &v.PCompressorInMin, &v.PCompressorInMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.PCompressorOut }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.PCompressorOutAverage, &v.PCompressorOutMin, &v.PCompressorOutMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.PCompressorInTarget }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.PCompressorInTargetAverage, &v.PCompressorInTargetMin, &v.PCompressorInTargetMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TCompressorIn }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TCompressorInAverage, &v.TCompressorInMin, &v.TCompressorInMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TCompressorOut }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TCompressorOutAverage, &v.TCompressorOutMin, &v.TCompressorOutMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TCompressorInTransient }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TCompressorInTransientAverage, &v.TCompressorInTransientMin, &v.TCompressorInTransientMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TCompressorOutTransient }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TCompressorOutTransientAverage, &v.TCompressorOutTransientMin, &v.TCompressorOutTransientMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.DeltaTCompressorInSuperheat }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.DeltaTCompressorInSuperheatAverage, &v.DeltaTCompressorInSuperheatMin, &v.DeltaTCompressorInSuperheatMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.Fan }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.FanAverage, &v.FanMin, &v.FanMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.FanPower }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.FanPowerAverage, &v.FanPowerMin, &v.FanPowerMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TemperatureErrorIntegral }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TemperatureErrorIntegralAverage, &v.TemperatureErrorIntegralMin, &v.TemperatureErrorIntegralMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TBoard }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TBoardAverage, &v.TBoardMin, &v.TBoardMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.TInverter }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.TInverterAverage, &v.TInverterMin, &v.TInverterMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.CompressorPowerLowAccuracy }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CompressorPowerLowAccuracyAverage, &v.CompressorPowerLowAccuracyMin, &v.CompressorPowerLowAccuracyMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.InverterInputVoltage }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.InverterInputVoltageAverage, &v.InverterInputVoltageMin, &v.InverterInputVoltageMax
+	}},
+	{integer(chDuty), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CentralHeatingPWMRequestedDutyCycleAverage, &v.CentralHeatingPWMRequestedDutyCycleMin, &v.CentralHeatingPWMRequestedDutyCycleMax
+	}},
+	{integer(dhwDuty), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.DHWPWMRequestedDutyCycleAverage, &v.DHWPWMRequestedDutyCycleMin, &v.DHWPWMRequestedDutyCycleMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.IndoorUnitHeaterTemperature }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.IndoorUnitHeaterTemperatureAverage, &v.IndoorUnitHeaterTemperatureMin, &v.IndoorUnitHeaterTemperatureMax
+	}},
+	{float(func(l *RawHeatPumpLog) *float64 { return l.IndoorUnitInputCurrent }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.IndoorUnitInputCurrentAverage, &v.IndoorUnitInputCurrentMin, &v.IndoorUnitInputCurrentMax
+	}},
+	{integer(func(l *RawHeatPumpLog) *int { return l.SINR }), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.SignalSINRAverage, &v.SignalSINRMin, &v.SignalSINRMax
+	}},
+
+	{powerIn(modeStandby), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInStandbyAverage, &v.CMMassPowerInStandbyMin, &v.CMMassPowerInStandbyMax
+	}},
+	{powerIn(modeHeating), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInHeatingAverage, &v.CMMassPowerInHeatingMin, &v.CMMassPowerInHeatingMax
+	}},
+	{powerIn(modeCooling), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInCoolingAverage, &v.CMMassPowerInCoolingMin, &v.CMMassPowerInCoolingMax
+	}},
+	{powerIn(modeHeatingDefrost), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInHeatingDefrostAverage, &v.CMMassPowerInHeatingDefrostMin, &v.CMMassPowerInHeatingDefrostMax
+	}},
+	{powerIn(modeDHWDefrost), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInDHWDefrostAverage, &v.CMMassPowerInDHWDefrostMin, &v.CMMassPowerInDHWDefrostMax
+	}},
+	{powerIn(modeHeatingDefrost, modeDHWDefrost), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInDefrostAverage, &v.CMMassPowerInDefrostMin, &v.CMMassPowerInDefrostMax
+	}},
+	{powerIn(modeDHW, modeLegionella), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInDHWAverage, &v.CMMassPowerInDHWMin, &v.CMMassPowerInDHWMax
+	}},
+	{powerIn(modeManualControl), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerInManualControlAverage, &v.CMMassPowerInManualControlMin, &v.CMMassPowerInManualControlMax
+	}},
+	{powerOut(modeStandby), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutStandbyAverage, &v.CMMassPowerOutStandbyMin, &v.CMMassPowerOutStandbyMax
+	}},
+	{powerOut(modeHeating), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutHeatingAverage, &v.CMMassPowerOutHeatingMin, &v.CMMassPowerOutHeatingMax
+	}},
+	{powerOut(modeCooling), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutCoolingAverage, &v.CMMassPowerOutCoolingMin, &v.CMMassPowerOutCoolingMax
+	}},
+	{powerOut(modeHeatingDefrost), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutHeatingDefrostAverage, &v.CMMassPowerOutHeatingDefrostMin, &v.CMMassPowerOutHeatingDefrostMax
+	}},
+	{powerOut(modeDHWDefrost), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutDHWDefrostAverage, &v.CMMassPowerOutDHWDefrostMin, &v.CMMassPowerOutDHWDefrostMax
+	}},
+	{powerOut(modeHeatingDefrost, modeDHWDefrost), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutDefrostAverage, &v.CMMassPowerOutDefrostMin, &v.CMMassPowerOutDefrostMax
+	}},
+	{powerOut(modeDHW, modeLegionella), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutDHWAverage, &v.CMMassPowerOutDHWMin, &v.CMMassPowerOutDHWMax
+	}},
+	{powerOut(modeManualControl), func(v *HeatPumpLogView) (**float64, **float64, **float64) {
+		return &v.CMMassPowerOutManualControlAverage, &v.CMMassPowerOutManualControlMin, &v.CMMassPowerOutManualControlMax
+	}},
+}
+
+// runningStat accumulates a duration-weighted average with its extremes.
+type runningStat struct {
+	sum, weight, min, max float64
+	n                     int
+}
+
+func (s *runningStat) add(value *float64, weight float64) {
+	if value == nil {
+		return
+	}
+	v := *value
+	if s.n == 0 || v < s.min {
+		s.min = v
+	}
+	if s.n == 0 || v > s.max {
+		s.max = v
+	}
+	s.n++
+	s.sum += v * weight
+	s.weight += weight
+}
+
+func (s *runningStat) average() float64 {
+	if s.weight == 0 {
+		return 0
+	}
+	return s.sum / s.weight
+}
+
+func (b *logBucket) logView(interval LogInterval) HeatPumpLogView {
+	view := HeatPumpLogView{
+		TimeBucket: timePtr(b.start),
+		Interval:   stringPtr(string(interval)),
+	}
+
+	var covered float64
+	counters := make([]float64, len(logCounters))
+	stats := make([]runningStat, len(logStats))
+	var signal, valve runningStat
+	for i := range b.logs {
+		log := &b.logs[i]
+		seconds := b.seconds[i]
+		covered += seconds
+		mode := logMode(log)
+		for j, counter := range logCounters {
+			if counter.match(log, mode) {
+				counters[j] += seconds
+			}
+		}
+		// Logs without an interval still count towards averages.
+		weight := max(seconds, 1)
+		for j, stat := range logStats {
+			stats[j].add(stat.value(log, mode), weight)
+		}
+		signal.add(intToFloat(log.SignalStrength), weight)
+		valve.add(log.Valve, weight)
+	}
+
+	view.TimeCoveredInInterval = intPtr(int(math.Round(covered)))
+	for j, counter := range logCounters {
+		*counter.field(&view) = intPtr(int(math.Round(counters[j])))
+	}
+	for j, stat := range logStats {
+		if stats[j].n == 0 {
+			continue
+		}
+		avg, lo, hi := stat.field(&view)
+		*avg, *lo, *hi = floatPtr(stats[j].average()), floatPtr(stats[j].min), floatPtr(stats[j].max)
+	}
+	if signal.n > 0 {
+		view.SignalStrengthAverage = floatPtr(signal.average())
+		view.SignalStrengthMin = intPtr(int(signal.min))
+		view.SignalStrengthMax = intPtr(int(signal.max))
+	}
+	if valve.n > 0 {
+		view.ValveAverage = intPtr(int(math.Round(valve.average())))
+		view.ValveMin = intPtr(int(math.Round(valve.min)))
+		view.ValveMax = intPtr(int(math.Round(valve.max)))
+	}
+	return view
+}
+
+func (b *logBucket) energyView(interval string) EnergyView {
+	view := EnergyView{
+		Interval:   stringPtr(interval),
+		TimeBucket: timePtr(b.start),
+	}
+	for i := range b.logs {
+		log := &b.logs[i]
+		if log.CMMassPowerIn == nil || log.CMMassPowerOut == nil {
+			continue
+		}
+		hours := b.seconds[i] / 3600
+		in := float64(*log.CMMassPowerIn) * hours / 1000
+		out := float64(*log.CMMassPowerOut) * hours / 1000
+		switch logMode(log) {
+		case modeHeating:
+			view.TotalEInHeating += in
+			view.TotalEOutHeating += out
+		case modeCooling:
+			view.TotalEInCooling += in
+			view.TotalEOutCooling += out
+		case modeDHW, modeLegionella:
+			view.TotalEInDHW += in
+			view.TotalEOutDHW += out
+		case modeHeatingDefrost:
+			view.TotalEInHeatingDefrost += in
+			view.TotalEOutHeatingDefrost += out
+		case modeDHWDefrost:
+			view.TotalEInDHWDefrost += in
+			view.TotalEOutDHWDefrost += out
+		default:
+			view.TotalEInStandby += in
+		}
+	}
+
+	watts := 1000 / b.end.Sub(b.start).Hours()
+	view.AveragePowerEInHeating = view.TotalEInHeating * watts
+	view.AveragePowerEInStandby = view.TotalEInStandby * watts
+	view.AveragePowerEInDHW = view.TotalEInDHW * watts
+	view.AveragePowerEInHeatingDefrost = view.TotalEInHeatingDefrost * watts
+	view.AveragePowerEInDHWDefrost = view.TotalEInDHWDefrost * watts
+	view.AveragePowerEInCooling = view.TotalEInCooling * watts
+	view.AveragePowerEOutHeating = view.TotalEOutHeating * watts
+	view.AveragePowerEOutDHW = view.TotalEOutDHW * watts
+	view.AveragePowerEOutHeatingDefrost = view.TotalEOutHeatingDefrost * watts
+	view.AveragePowerEOutDHWDefrost = view.TotalEOutDHWDefrost * watts
+	view.AveragePowerEOutCooling = view.TotalEOutCooling * watts
+	return view
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
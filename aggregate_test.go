@@ -0,0 +1,135 @@
+package weheat_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+func TestLogViewsReportCoverage(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	srv := weheattest.NewServer(weheattest.ServerConfig{
+		Clock: func() time.Time { return now },
+		Pumps: []*weheattest.SimulatedPump{{
+			ID:      "hp",
+			State:   weheat.DeviceStateActive,
+			Outages: []weheattest.Window{{Start: now.Add(-105 * time.Minute), End: now.Add(-75 * time.Minute)}},
+		}},
+	})
+	defer srv.Close()
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := now.Add(-3 * time.Hour)
+	views, err := client.GetLogs(testContext(t), "hp", weheat.LogQuery{StartTime: &start, EndTime: &now, Interval: weheat.LogIntervalHour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 3 {
+		t.Fatalf("views = %d, want 3 hourly buckets", len(views))
+	}
+	for i, want := range []int{3600, 1800, 3600} {
+		view := views[i]
+		if !view.TimeBucket.Equal(start.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("bucket %d starts at %v", i, view.TimeBucket)
+		}
+		if view.TimeCoveredInInterval == nil || *view.TimeCoveredInInterval != want {
+			t.Errorf("bucket %d covers %v seconds, want %d", i, view.TimeCoveredInInterval, want)
+		}
+	}
+}
+
+func TestAggregateEnergyLogsRejectsLogIntervals(t *testing.T) {
+	logs := []weheat.RawHeatPumpLog{{Timestamp: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), Interval: 30}}
+	if views := weheat.AggregateEnergyLogs(logs, weheat.EnergyIntervalHour); len(views) != 1 {
+		t.Fatalf("hourly energy views = %d, want 1", len(views))
+	}
+	for _, interval := range []weheat.EnergyInterval{"Minute", "FifteenMinute", ""} {
+		if views := weheat.AggregateEnergyLogs(logs, interval); views != nil {
+			t.Errorf("interval %q: views = %v, want nil", interval, views)
+		}
+	}
+
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetEnergyLogs(testContext(t), defaultPumpID, weheat.EnergyLogQuery{Interval: "Minute"})
+	if !errors.Is(err, weheat.ErrBadRequest) {
+		t.Fatalf("interval=Minute err = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestAggregateRawLogsBucketsAndHonoursLocation(t *testing.T) {
+	now := time.Date(2024, 1, 15, 2, 0, 0, 0, time.UTC)
+	start := now.Add(-4 * time.Hour)
+	// 23:30–23:50 UTC is missing.
+	logs := slices.Concat(samples(start, now.Add(-150*time.Minute)), samples(now.Add(-130*time.Minute), now))
+
+	hours := weheat.AggregateRawLogs(logs, weheat.LogIntervalHour, nil)
+	assertBuckets(t, hours, []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour), start.Add(3 * time.Hour)}, []int64{3600, 2400, 3600, 3600})
+
+	// 22:00–02:00 UTC is 23:00–03:00 at UTC+1: two local days, split at 23:00 UTC.
+	cet := time.FixedZone("CET", 3600)
+	days := weheat.AggregateRawLogs(logs, weheat.LogIntervalDay, cet)
+	assertBuckets(t, days, []time.Time{time.Date(2024, 1, 14, 0, 0, 0, 0, cet), time.Date(2024, 1, 15, 0, 0, 0, 0, cet)}, []int64{3600, 3*3600 - 1200})
+}
+
+func TestAggregateRawLogsAcrossDSTChanges(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	hour := func(day, h int) time.Time { return time.Date(2026, time.October, day, h, 0, 0, 0, time.UTC) }
+
+	// Clocks go back at 01:00 UTC on 25 October, repeating 02:00–03:00 local.
+	// Hours stay an hour long rather than merging.
+	fallBack := samples(hour(25, 0), hour(25, 3))
+	assertBuckets(t, weheat.AggregateRawLogs(fallBack, weheat.LogIntervalHour, amsterdam),
+		[]time.Time{hour(25, 0), hour(25, 1), hour(25, 2)}, []int64{3600, 3600, 3600})
+	assertBuckets(t, weheat.AggregateRawLogs(fallBack, weheat.LogIntervalFifteenMinute, amsterdam)[3:5],
+		[]time.Time{hour(25, 0).Add(45 * time.Minute), hour(25, 1)}, []int64{900, 900})
+	// That local day lasts 25 hours.
+	day := samples(time.Date(2026, time.October, 25, 0, 0, 0, 0, amsterdam), time.Date(2026, time.October, 26, 0, 0, 0, 0, amsterdam))
+	assertBuckets(t, weheat.AggregateRawLogs(day, weheat.LogIntervalDay, amsterdam),
+		[]time.Time{hour(24, 22)}, []int64{25 * 3600})
+
+	// Clocks go forward at 01:00 UTC on 29 March, skipping 02:00–03:00 local.
+	spring := func(h int) time.Time { return time.Date(2026, time.March, 29, h, 0, 0, 0, time.UTC) }
+	forward := samples(spring(0), spring(3))
+	assertBuckets(t, weheat.AggregateRawLogs(forward, weheat.LogIntervalHour, amsterdam),
+		[]time.Time{spring(0), spring(1), spring(2)}, []int64{3600, 3600, 3600})
+	// That local day lasts 23 hours.
+	day = samples(time.Date(2026, time.March, 29, 0, 0, 0, 0, amsterdam), time.Date(2026, time.March, 30, 0, 0, 0, 0, amsterdam))
+	assertBuckets(t, weheat.AggregateRawLogs(day, weheat.LogIntervalDay, amsterdam),
+		[]time.Time{time.Date(2026, time.March, 28, 23, 0, 0, 0, time.UTC)}, []int64{23 * 3600})
+}
+
+// samples returns 30-second raw logs in [start, end).
+func samples(start, end time.Time) []weheat.RawHeatPumpLog {
+	var logs []weheat.RawHeatPumpLog
+	for t := start; t.Before(end); t = t.Add(30 * time.Second) {
+		logs = append(logs, weheat.RawHeatPumpLog{Timestamp: t, Interval: 30, TAirIn: float64Ptr(5)})
+	}
+	return logs
+}
+
+func assertBuckets(t *testing.T, views []weheat.HeatPumpLogView, starts []time.Time, covered []int64) {
+	t.Helper()
+	if len(views) != len(starts) {
+		t.Fatalf("%d buckets, want %d", len(views), len(starts))
+	}
+	for i, view := range views {
+		if !view.TimeBucket.Equal(starts[i]) || int64(*view.TimeCoveredInInterval) != covered[i] {
+			t.Errorf("bucket %d starts %v covering %d, want %v covering %d", i,
+				view.TimeBucket, *view.TimeCoveredInInterval, starts[i], covered[i])
+		}
+	}
+}
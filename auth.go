@@ -2,15 +2,33 @@ package weheat
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 )
 
 const (
-	DefaultTokenURL = "https://auth.weheat.nl/auth/realms/Weheat/protocol/openid-connect/token/"
+	DefaultTokenURL      = "https://auth.weheat.nl/auth/realms/Weheat/protocol/openid-connect/token/"
+	DefaultAuthURL       = "https://auth.weheat.nl/auth/realms/Weheat/protocol/openid-connect/auth"
+	DefaultDeviceAuthURL = "https://auth.weheat.nl/auth/realms/Weheat/protocol/openid-connect/auth/device"
+	DefaultRevocationURL = "https://auth.weheat.nl/auth/realms/Weheat/protocol/openid-connect/revoke"
+	DefaultLogoutURL     = "https://auth.weheat.nl/auth/realms/Weheat/protocol/openid-connect/logout"
 )
 
+// ErrTokenRevoked matches OAuth errors for tokens that are already revoked or invalid.
+var ErrTokenRevoked = errors.New("weheat: token revoked or invalid")
+
 var DefaultScopes = []string{"openid", "offline_access"}
 
 // TokenSource provides access tokens for API requests.
@@ -32,45 +50,151 @@ func (s StaticToken) Token(_ context.Context) (string, error) {
 type OAuthConfig struct {
 	ClientID     string
 	ClientSecret string
-	TokenURL     string
-	RefreshToken string
-	Scopes       []string
+	// Issuer, if set, is used to discover any endpoint URL left empty. The
+	// production defaults are then never used: an endpoint the issuer does
+	// not advertise is an error.
+	Issuer   string
+	TokenURL string
+	AuthURL  string
+	// DeviceAuthURL is used by LoginWithDevice.
+	DeviceAuthURL string
+	// RevocationURL and LogoutURL are used by RevokeToken and Logout.
+	RevocationURL string
+	LogoutURL     string
+	RefreshToken  string
+	Scopes        []string
+	// Store, if set, supplies the refresh token and receives every rotated token.
+	Store TokenStore
+	// OnSaveError is called when Store fails to save a rotated token. The
+	// new access token is still used and the save is retried on the next
+	// call to Token.
+	OnSaveError func(error)
+	// RefreshSkew refreshes the access token this long before it expires.
+	// Defaults to the oauth2 package's 10 seconds.
+	RefreshSkew time.Duration
 }
 
 // OAuthTokenSource returns a TokenSource that refreshes access tokens using a refresh token.
-func OAuthTokenSource(cfg OAuthConfig) (TokenSource, error) {
+// When cfg.Store holds a token it takes precedence over cfg.RefreshToken, since
+// the stored one reflects the latest rotation. ctx bounds loading the stored
+// token and discovery; refreshes use its values but not its cancellation.
+func OAuthTokenSource(ctx context.Context, cfg OAuthConfig) (TokenSource, error) {
 	if cfg.ClientID == "" {
 		return nil, errors.New("weheat: client_id required")
 	}
-	if cfg.TokenURL == "" {
-		cfg.TokenURL = DefaultTokenURL
+	initial := &oauth2.Token{RefreshToken: cfg.RefreshToken}
+	saved := ""
+	if cfg.Store != nil {
+		stored, err := cfg.Store.Load(ctx)
+		switch {
+		case err == nil && stored.RefreshToken != "":
+			initial = stored
+			saved = stored.RefreshToken
+		case err != nil && !errors.Is(err, ErrNoToken):
+			return nil, err
+		}
 	}
-	if cfg.RefreshToken == "" {
+	if initial.RefreshToken == "" {
 		return nil, errors.New("weheat: refresh token required")
 	}
+	conf, err := cfg.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newOAuthTokenSource(ctx, cfg, conf, initial, saved), nil
+}
+
+func newOAuthTokenSource(ctx context.Context, cfg OAuthConfig, conf *oauth2.Config, initial *oauth2.Token, saved string) *oauthTokenSource {
+	src := conf.TokenSource(context.WithoutCancel(ctx), initial)
+	if cfg.RefreshSkew > 0 {
+		src = oauth2.ReuseTokenSourceWithExpiry(initial, src, cfg.RefreshSkew)
+	}
+	return &oauthTokenSource{source: src, store: cfg.Store, onSaveError: cfg.OnSaveError, saved: saved}
+}
+
+// resolve fills empty endpoint URLs from the issuer's discovery document.
+func (cfg OAuthConfig) resolve(ctx context.Context) (OAuthConfig, error) {
+	if cfg.Issuer == "" {
+		return cfg, nil
+	}
+	discovered, err := OIDCDiscover(ctx, cfg.Issuer)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = discovered.TokenEndpoint
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = discovered.AuthorizationEndpoint
+	}
+	if cfg.DeviceAuthURL == "" {
+		cfg.DeviceAuthURL = discovered.DeviceAuthorizationEndpoint
+	}
+	if cfg.RevocationURL == "" {
+		cfg.RevocationURL = discovered.RevocationEndpoint
+	}
+	if cfg.LogoutURL == "" {
+		cfg.LogoutURL = discovered.EndSessionEndpoint
+	}
+	return cfg, nil
+}
+
+func (cfg OAuthConfig) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	cfg, err := cfg.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokenURL, err := cfg.endpoint(cfg.TokenURL, DefaultTokenURL, "token_endpoint")
+	if err != nil {
+		return nil, err
+	}
+	// Only the login flows need these; they report a missing one themselves.
+	authURL, _ := cfg.endpoint(cfg.AuthURL, DefaultAuthURL, "authorization_endpoint")
+	deviceAuthURL, _ := cfg.endpoint(cfg.DeviceAuthURL, DefaultDeviceAuthURL, "device_authorization_endpoint")
 	scopes := cfg.Scopes
 	if len(scopes) == 0 {
 		scopes = append([]string(nil), DefaultScopes...)
 	}
-
-	oauthCfg := &oauth2.Config{
+	conf := &oauth2.Config{
 		ClientID:     cfg.ClientID,
 		ClientSecret: cfg.ClientSecret,
 		Endpoint: oauth2.Endpoint{
-			TokenURL: cfg.TokenURL,
+			AuthURL:       authURL,
+			DeviceAuthURL: deviceAuthURL,
+			TokenURL:      tokenURL,
+			// Keycloak takes client credentials as form parameters. Leaving
+			// the style unknown makes x/oauth2 resend every failed token
+			// request, which turns each pending device poll into two.
+			AuthStyle: oauth2.AuthStyleInParams,
 		},
 		Scopes: scopes,
 	}
+	return conf, nil
+}
 
-	src := oauthCfg.TokenSource(context.Background(), &oauth2.Token{RefreshToken: cfg.RefreshToken})
-	return oauthTokenSource{source: src}, nil
+// endpoint returns value, falling back to the production default only when
+// no issuer is configured, so a staging or test realm never sends
+// credentials to production.
+func (cfg OAuthConfig) endpoint(value, fallback, name string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	if cfg.Issuer != "" {
+		return "", fmt.Errorf("weheat: issuer does not advertise %s", name)
+	}
+	return fallback, nil
 }
 
 type oauthTokenSource struct {
-	source oauth2.TokenSource
+	source      oauth2.TokenSource
+	store       TokenStore
+	onSaveError func(error)
+
+	mu    sync.Mutex
+	saved string
 }
 
-func (o oauthTokenSource) Token(ctx context.Context) (string, error) {
+func (o *oauthTokenSource) Token(ctx context.Context) (string, error) {
 	token, err := o.source.Token()
 	if err != nil {
 		return "", err
@@ -78,5 +202,336 @@ func (o oauthTokenSource) Token(ctx context.Context) (string, error) {
 	if token.AccessToken == "" {
 		return "", errors.New("weheat: empty access token")
 	}
+	if err := o.persist(ctx, token); err != nil && o.onSaveError != nil {
+		o.onSaveError(err)
+	}
 	return token.AccessToken, nil
 }
+
+// persist writes the token to the store whenever the refresh token rotates.
+// A failed save leaves saved unchanged so the next call tries again.
+func (o *oauthTokenSource) persist(ctx context.Context, token *oauth2.Token) error {
+	if o.store == nil || token.RefreshToken == "" {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if token.RefreshToken == o.saved {
+		return nil
+	}
+	if err := o.store.Save(ctx, token); err != nil {
+		return fmt.Errorf("weheat: persist rotated token: %w", err)
+	}
+	o.saved = token.RefreshToken
+	return nil
+}
+
+// BrowserLogin configures the interactive authorization-code login.
+type BrowserLogin struct {
+	// ListenAddr is the loopback address for the redirect listener. The
+	// redirect URI registered with Keycloak must match it; defaults to
+	// "127.0.0.1:0".
+	ListenAddr string
+	// CallbackPath defaults to "/callback".
+	CallbackPath string
+	// OpenURL presents the authorization URL to the user; defaults to
+	// launching the system browser.
+	OpenURL func(authURL string) error
+}
+
+// LoginWithBrowser runs the authorization-code flow with PKCE, receiving the
+// redirect on a local listener. The returned token carries the refresh token
+// for OAuthTokenSource and is saved to cfg.Store when one is set.
+func LoginWithBrowser(ctx context.Context, cfg OAuthConfig, login BrowserLogin) (*oauth2.Token, error) {
+	if cfg.ClientID == "" {
+		return nil, errors.New("weheat: client_id required")
+	}
+	addr := login.ListenAddr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	callbackPath := login.CallbackPath
+	if callbackPath == "" {
+		callbackPath = "/callback"
+	}
+	openURL := login.OpenURL
+	if openURL == nil {
+		openURL = openBrowser
+	}
+
+	conf, err := cfg.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Endpoint.AuthURL == "" {
+		return nil, errors.New("weheat: issuer does not advertise authorization_endpoint")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	conf.RedirectURL = "http://" + listener.Addr().String() + callbackPath
+
+	state := oauth2.GenerateVerifier()
+	verifier := oauth2.GenerateVerifier()
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var res result
+		switch {
+		case query.Get("state") != state:
+			res.err = errors.New("weheat: login state mismatch")
+		case query.Get("error") != "":
+			res.err = fmt.Errorf("weheat: login failed: %s: %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("code") == "":
+			res.err = errors.New("weheat: login callback missing code")
+		default:
+			res.code = query.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Login complete. You can close this window.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authURL := conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+	if err := openURL(authURL); err != nil {
+		return nil, err
+	}
+
+	var res result
+	select {
+	case res = <-results:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	token, err := conf.Exchange(ctx, res.code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		return nil, errors.New("weheat: login returned no refresh token")
+	}
+	if cfg.Store != nil {
+		if err := cfg.Store.Save(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+// DeviceCode is what the user needs to approve a device login.
+type DeviceCode struct {
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	Expiry                  time.Time
+}
+
+// LoginWithDevice runs the OAuth device authorization grant for headless
+// installs. prompt is called once with the code the user must enter at the
+// verification URL; the realm is then polled until the user approves, backing
+// off when asked to slow down. The resulting token is saved to cfg.Store when
+// one is set.
+func LoginWithDevice(ctx context.Context, cfg OAuthConfig, prompt func(DeviceCode) error) (TokenSource, error) {
+	if cfg.ClientID == "" {
+		return nil, errors.New("weheat: client_id required")
+	}
+	if prompt == nil {
+		return nil, errors.New("weheat: device code prompt required")
+	}
+
+	conf, err := cfg.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Endpoint.DeviceAuthURL == "" {
+		return nil, errors.New("weheat: issuer does not advertise device_authorization_endpoint")
+	}
+	auth, err := conf.DeviceAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	code := DeviceCode{
+		UserCode:                auth.UserCode,
+		VerificationURI:         auth.VerificationURI,
+		VerificationURIComplete: auth.VerificationURIComplete,
+		Expiry:                  auth.Expiry,
+	}
+	if err := prompt(code); err != nil {
+		return nil, err
+	}
+
+	token, err := conf.DeviceAccessToken(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		return nil, errors.New("weheat: device login returned no refresh token")
+	}
+	if cfg.Store != nil {
+		if err := cfg.Store.Save(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	return newOAuthTokenSource(ctx, cfg, conf, token, token.RefreshToken), nil
+}
+
+// OAuthError is an error response from the OAuth server.
+type OAuthError struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("weheat: oauth error %d: %s", e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("weheat: oauth error %d: %s: %s", e.StatusCode, e.Code, e.Description)
+}
+
+// Is matches ErrTokenRevoked for invalid_token and invalid_grant errors.
+func (e *OAuthError) Is(target error) bool {
+	return target == ErrTokenRevoked && (e.Code == "invalid_token" || e.Code == "invalid_grant")
+}
+
+// RevokeToken revokes a refresh or access token at the revocation endpoint
+// and clears cfg.Store if it is a TokenClearer. tokenTypeHint may be
+// "refresh_token", "access_token" or empty.
+func RevokeToken(ctx context.Context, cfg OAuthConfig, token string, tokenTypeHint string) error {
+	if token == "" {
+		return errors.New("weheat: token required")
+	}
+	cfg, err := cfg.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint, err := cfg.endpoint(cfg.RevocationURL, DefaultRevocationURL, "revocation_endpoint")
+	if err != nil {
+		return err
+	}
+	form := url.Values{"token": {token}}
+	if tokenTypeHint != "" {
+		form.Set("token_type_hint", tokenTypeHint)
+	}
+	return cfg.postAndClear(ctx, endpoint, form)
+}
+
+// Logout ends the Keycloak session behind the refresh token held by cfg.Store
+// (or cfg.RefreshToken) and clears the store if it is a TokenClearer.
+func Logout(ctx context.Context, cfg OAuthConfig) error {
+	refreshToken := cfg.RefreshToken
+	if cfg.Store != nil {
+		stored, err := cfg.Store.Load(ctx)
+		switch {
+		case err == nil && stored.RefreshToken != "":
+			refreshToken = stored.RefreshToken
+		case err != nil && !errors.Is(err, ErrNoToken):
+			return err
+		}
+	}
+	if refreshToken == "" {
+		return errors.New("weheat: refresh token required")
+	}
+	cfg, err := cfg.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint, err := cfg.endpoint(cfg.LogoutURL, DefaultLogoutURL, "end_session_endpoint")
+	if err != nil {
+		return err
+	}
+	return cfg.postAndClear(ctx, endpoint, url.Values{"refresh_token": {refreshToken}})
+}
+
+// postAndClear posts an authenticated form and clears the token store once
+// the server has answered, including when the token was already revoked.
+func (cfg OAuthConfig) postAndClear(ctx context.Context, endpoint string, form url.Values) error {
+	if cfg.ClientID == "" {
+		return errors.New("weheat: client_id required")
+	}
+	form.Set("client_id", cfg.ClientID)
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient(ctx).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var callErr error
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		oauthErr := &OAuthError{StatusCode: resp.StatusCode}
+		var payload struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &payload) == nil {
+			oauthErr.Code = payload.Error
+			oauthErr.Description = payload.ErrorDescription
+		}
+		if oauthErr.Code == "" {
+			oauthErr.Description = strings.TrimSpace(string(body))
+		}
+		if !errors.Is(oauthErr, ErrTokenRevoked) {
+			return oauthErr
+		}
+		callErr = oauthErr
+	}
+
+	if clearer, ok := cfg.Store.(TokenClearer); ok {
+		if err := clearer.Clear(ctx); err != nil {
+			return err
+		}
+	}
+	return callErr
+}
+
+func openBrowser(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
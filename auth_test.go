@@ -0,0 +1,342 @@
+package weheat_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+	"golang.org/x/oauth2"
+)
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// followAuthURL plays the browser: it requests the authorization URL and
+// follows the realm's redirect to the login callback.
+func followAuthURL(t *testing.T, rewrite func(url.Values)) func(string) error {
+	return func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		if rewrite != nil {
+			query := u.Query()
+			rewrite(query)
+			u.RawQuery = query.Encode()
+		}
+		go func() {
+			resp, err := http.Get(u.String())
+			if err != nil {
+				t.Errorf("browser: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		return nil
+	}
+}
+
+func TestLoginWithBrowser(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+	ctx := testContext(t)
+
+	cfg := weheat.OAuthConfig{
+		ClientID: srv.OAuthConfig().ClientID,
+		Issuer:   srv.Issuer(),
+		Store:    weheat.NewMemoryTokenStore(nil),
+	}
+	token, err := weheat.LoginWithBrowser(ctx, cfg, weheat.BrowserLogin{OpenURL: followAuthURL(t, nil)})
+	if err != nil {
+		t.Fatalf("LoginWithBrowser: %v", err)
+	}
+	if token.RefreshToken == "" || token.AccessToken == "" {
+		t.Fatalf("token missing fields: %+v", token)
+	}
+	stored, err := cfg.Store.Load(ctx)
+	if err != nil || stored.RefreshToken != token.RefreshToken {
+		t.Fatalf("store holds %v, %v; want the login token", stored, err)
+	}
+
+	// The stored refresh token works for API calls.
+	source, err := weheat.OAuthTokenSource(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := weheat.NewClient(weheat.WithBaseURL(srv.URL), weheat.WithTokenSource(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetUserMe(ctx, weheat.RequestOptions{}); err != nil {
+		t.Fatalf("GetUserMe: %v", err)
+	}
+}
+
+func TestLoginWithBrowserRejectsWrongVerifier(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+
+	cfg := weheat.OAuthConfig{ClientID: srv.OAuthConfig().ClientID, Issuer: srv.Issuer()}
+	tamper := func(query url.Values) {
+		query.Set("code_challenge", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM")
+	}
+	_, err := weheat.LoginWithBrowser(testContext(t), cfg, weheat.BrowserLogin{OpenURL: followAuthURL(t, tamper)})
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("err = %v, want invalid_grant", err)
+	}
+}
+
+func TestLoginWithBrowserStateMismatch(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+
+	cfg := weheat.OAuthConfig{ClientID: srv.OAuthConfig().ClientID, Issuer: srv.Issuer()}
+	tamper := func(query url.Values) { query.Set("state", "forged") }
+	_, err := weheat.LoginWithBrowser(testContext(t), cfg, weheat.BrowserLogin{OpenURL: followAuthURL(t, tamper)})
+	if err == nil || !strings.Contains(err.Error(), "state mismatch") {
+		t.Fatalf("err = %v, want state mismatch", err)
+	}
+}
+
+func TestLoginWithBrowserOpenURLError(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+
+	want := errors.New("no browser")
+	cfg := weheat.OAuthConfig{ClientID: srv.OAuthConfig().ClientID, Issuer: srv.Issuer()}
+	_, err := weheat.LoginWithBrowser(testContext(t), cfg, weheat.BrowserLogin{OpenURL: func(string) error { return want }})
+	if !errors.Is(err, want) {
+		t.Fatalf("err = %v, want %v", err, want)
+	}
+}
+
+// devicePoller watches device-code token polls, approving the login after the
+// first one. With double set it also sends the first poll twice in a row, as
+// an impatient client would, and returns the second answer.
+type devicePoller struct {
+	double  bool
+	approve string
+
+	mu     sync.Mutex
+	errors []string
+}
+
+func (p *devicePoller) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/token") {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	send := func() (*http.Response, error) {
+		clone := req.Clone(req.Context())
+		clone.Body = io.NopCloser(bytes.NewReader(body))
+		resp, err := http.DefaultTransport.RoundTrip(clone)
+		if err != nil {
+			return nil, err
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		var payload struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(data, &payload)
+		p.mu.Lock()
+		p.errors = append(p.errors, payload.Error)
+		p.mu.Unlock()
+		return resp, nil
+	}
+
+	resp, err := send()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	first := len(p.errors) == 1
+	p.mu.Unlock()
+	if !first {
+		return resp, nil
+	}
+	if p.double {
+		if resp, err = send(); err != nil {
+			return nil, err
+		}
+	}
+	approval, err := http.Get(p.approve)
+	if err != nil {
+		return nil, err
+	}
+	approval.Body.Close()
+	return resp, nil
+}
+
+func (p *devicePoller) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.errors)
+}
+
+func loginWithDevice(t *testing.T, double bool) (*devicePoller, time.Duration) {
+	t.Helper()
+	srv := weheattest.NewServer(weheattest.ServerConfig{DevicePollInterval: time.Second})
+	defer srv.Close()
+
+	poller := &devicePoller{double: double}
+	ctx := context.WithValue(testContext(t), oauth2.HTTPClient, &http.Client{Transport: poller})
+	store := weheat.NewMemoryTokenStore(nil)
+	cfg := weheat.OAuthConfig{ClientID: srv.OAuthConfig().ClientID, Issuer: srv.Issuer(), Store: store}
+
+	start := time.Now()
+	source, err := weheat.LoginWithDevice(ctx, cfg, func(code weheat.DeviceCode) error {
+		if code.UserCode == "" || !strings.Contains(code.VerificationURIComplete, code.UserCode) {
+			t.Errorf("unexpected device code %+v", code)
+		}
+		poller.approve = code.VerificationURIComplete
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoginWithDevice: %v", err)
+	}
+	elapsed := time.Since(start)
+	if access, err := source.Token(ctx); err != nil || access == "" {
+		t.Fatalf("Token = %q, %v", access, err)
+	}
+	if stored, err := store.Load(ctx); err != nil || stored.RefreshToken == "" {
+		t.Fatalf("store holds %v, %v; want the device token", stored, err)
+	}
+	return poller, elapsed
+}
+
+func TestLoginWithDevicePending(t *testing.T) {
+	poller, _ := loginWithDevice(t, false)
+	if got, want := poller.seen(), []string{"authorization_pending", ""}; !slices.Equal(got, want) {
+		t.Fatalf("poll results = %q, want %q", got, want)
+	}
+}
+
+func TestLoginWithDeviceSlowDown(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out a slowed-down poll interval")
+	}
+	poller, elapsed := loginWithDevice(t, true)
+	if got, want := poller.seen(), []string{"authorization_pending", "slow_down", ""}; !slices.Equal(got, want) {
+		t.Fatalf("poll results = %q, want %q", got, want)
+	}
+	// After slow_down the client must wait the interval plus five seconds.
+	if elapsed < 6*time.Second {
+		t.Fatalf("login took %v; slow_down was not honoured", elapsed)
+	}
+}
+
+func TestLoginWithDeviceRequiresPrompt(t *testing.T) {
+	_, err := weheat.LoginWithDevice(testContext(t), weheat.OAuthConfig{ClientID: "x"}, nil)
+	if err == nil {
+		t.Fatal("want error without prompt")
+	}
+}
+
+func TestIssuerWithoutEndpointsNeverFallsBackToProduction(t *testing.T) {
+	var issuer string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"issuer":%q,"token_endpoint":%q}`, issuer, issuer+"/token")
+	}))
+	defer srv.Close()
+	issuer = srv.URL
+
+	// Anything leaving for another host would be a production fallback.
+	guard := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host != strings.TrimPrefix(srv.URL, "http://") {
+			t.Errorf("request to %s", req.URL)
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	ctx := context.WithValue(testContext(t), oauth2.HTTPClient, guard)
+	cfg := weheat.OAuthConfig{ClientID: "client", Issuer: issuer, RefreshToken: "refresh"}
+
+	_, err := weheat.LoginWithBrowser(ctx, cfg, weheat.BrowserLogin{OpenURL: func(string) error {
+		t.Error("browser opened")
+		return nil
+	}})
+	wantEndpointError(t, "LoginWithBrowser", err, "authorization_endpoint")
+	_, err = weheat.LoginWithDevice(ctx, cfg, func(weheat.DeviceCode) error { return nil })
+	wantEndpointError(t, "LoginWithDevice", err, "device_authorization_endpoint")
+	wantEndpointError(t, "RevokeToken", weheat.RevokeToken(ctx, cfg, "refresh", ""), "revocation_endpoint")
+	wantEndpointError(t, "Logout", weheat.Logout(ctx, cfg), "end_session_endpoint")
+}
+
+func wantEndpointError(t *testing.T, call string, err error, endpoint string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), "does not advertise "+endpoint) {
+		t.Errorf("%s err = %v, want missing %s", call, err, endpoint)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRefreshSkewRefreshesEarly(t *testing.T) {
+	// Tokens live 30s; the oauth2 default would reuse one for 20s.
+	srv := weheattest.NewServer(weheattest.ServerConfig{AccessTokenTTL: 30 * time.Second})
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		skew time.Duration
+		want int
+	}{
+		{0, 1},
+		{29 * time.Second, 2},
+	} {
+		var refreshes atomic.Int32
+		counting := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/token") {
+				refreshes.Add(1)
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+		ctx := context.WithValue(testContext(t), oauth2.HTTPClient, counting)
+		cfg := srv.OAuthConfig()
+		cfg.RefreshSkew = tc.skew
+		source, err := weheat.OAuthTokenSource(ctx, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := source.Token(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// A 29s skew leaves the token one second of use; the default 20s.
+		time.Sleep(1100 * time.Millisecond)
+		if _, err := source.Token(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := int(refreshes.Load()); got != tc.want {
+			t.Errorf("skew %v: %d token requests, want %d", tc.skew, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package weheat
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is an API response body kept by a Cache.
+type CachedResponse struct {
+	Body    []byte
+	ETag    string
+	Expires time.Time
+}
+
+// Cache stores API responses for WithCache. Implementations must be safe for
+// concurrent use. Keys include the request URL but not the access token, so a
+// cache should not be shared between accounts.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+}
+
+// DefaultCacheTTLs returns freshness lifetimes for the slow-changing endpoints.
+func DefaultCacheTTLs() map[Operation]time.Duration {
+	return map[Operation]time.Duration{
+		OperationGetUserMe:     time.Hour,
+		OperationGetHeatPump:   time.Hour,
+		OperationListHeatPumps: 10 * time.Minute,
+	}
+}
+
+// defaultMemoryCacheEntries is the MemoryCache size limit from NewMemoryCache.
+const defaultMemoryCacheEntries = 1024
+
+// MemoryCache is an in-memory Cache.
+type MemoryCache struct {
+	// MaxEntries caps the number of entries; 0 means no limit. When a Set
+	// goes over it, expired entries are dropped first, then the entries that
+	// expire soonest. Change it before the cache is in use.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*CachedResponse
+}
+
+// NewMemoryCache returns an empty in-memory cache holding up to 1024 entries.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{MaxEntries: defaultMemoryCacheEntries, entries: map[string]*CachedResponse{}}
+}
+
+// Get returns the entry for key.
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp, ok := m.entries[key]
+	return resp, ok
+}
+
+// Set stores the entry for key.
+func (m *MemoryCache) Set(key string, resp *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = map[string]*CachedResponse{}
+	}
+	m.entries[key] = resp
+	if m.MaxEntries > 0 && len(m.entries) > m.MaxEntries {
+		m.evict(time.Now())
+	}
+}
+
+// evict brings the cache back under MaxEntries. Expired entries are kept
+// until then because their ETags still save bodies on revalidation.
+func (m *MemoryCache) evict(now time.Time) {
+	for key, entry := range m.entries {
+		if !now.Before(entry.Expires) {
+			delete(m.entries, key)
+		}
+	}
+	for len(m.entries) > m.MaxEntries {
+		var oldest string
+		for key, entry := range m.entries {
+			if oldest == "" || entry.Expires.Before(m.entries[oldest].Expires) {
+				oldest = key
+			}
+		}
+		delete(m.entries, oldest)
+	}
+}
+
+type responseCache struct {
+	store Cache
+	ttls  map[Operation]time.Duration
+}
+
+func (rc *responseCache) ttl(r request) (time.Duration, bool) {
+	if rc == nil || r.method != http.MethodGet || r.stream {
+		return 0, false
+	}
+	ttl, ok := rc.ttls[r.operation]
+	return ttl, ok
+}
+
+// cacheKey identifies a response by method, path, query and the version
+// headers that can change its shape.
+func cacheKey(r request) string {
+	var b strings.Builder
+	b.WriteString(r.method)
+	b.WriteByte(' ')
+	b.WriteString(r.path)
+	if len(r.query) > 0 {
+		b.WriteByte('?')
+		b.WriteString(r.query.Encode())
+	}
+	if r.opts.XVersion != "" {
+		b.WriteString(" x-version=" + r.opts.XVersion)
+	}
+	if r.opts.XBackendVersion != "" {
+		b.WriteString(" x-backend-version=" + r.opts.XBackendVersion)
+	}
+	return b.String()
+}
+
+// sendCached serves fresh entries from the cache, revalidates stale ones
+// with If-None-Match and stores cacheable responses.
+func (c *Client) sendCached(ctx context.Context, r request, ttl time.Duration) (fetched, error) {
+	key := cacheKey(r)
+	entry, ok := c.cache.store.Get(key)
+	if ok && !r.opts.NoCache && time.Now().Before(entry.Expires) {
+		return fetched{body: entry.Body, cacheHit: true}, nil
+	}
+	// NoCache asks for a full response, so the entry is not revalidated.
+	if ok && entry.ETag != "" && !r.opts.NoCache {
+		r.ifNoneMatch = entry.ETag
+	}
+
+	result, resp, err := c.fetch(ctx, r)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		refreshed := *entry
+		refreshed.Expires = cacheExpiry(resp.Header, ttl, time.Now())
+		c.cache.store.Set(key, &refreshed)
+		result.body = entry.Body
+		return result, nil
+	}
+	if !noStore(resp.Header) {
+		c.cache.store.Set(key, &CachedResponse{
+			Body:    result.body,
+			ETag:    resp.Header.Get("ETag"),
+			Expires: cacheExpiry(resp.Header, ttl, time.Now()),
+		})
+	}
+	return result, nil
+}
+
+// cacheExpiry prefers the response's Cache-Control over the endpoint TTL.
+func cacheExpiry(header http.Header, ttl time.Duration, now time.Time) time.Time {
+	for _, directive := range cacheControl(header) {
+		name, value, _ := strings.Cut(directive, "=")
+		switch name {
+		case "no-cache":
+			return now
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				return now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	return now.Add(ttl)
+}
+
+func noStore(header http.Header) bool {
+	for _, directive := range cacheControl(header) {
+		if directive == "no-store" {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheControl(header http.Header) []string {
+	var out []string
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if directive = strings.ToLower(strings.TrimSpace(directive)); directive != "" {
+				out = append(out, directive)
+			}
+		}
+	}
+	return out
+}
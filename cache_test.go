@@ -0,0 +1,68 @@
+package weheat_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+func TestCacheRevalidatesAndHonoursNoCache(t *testing.T) {
+	var requests, conditional atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=0")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, `{"id":"user","firstName":"Ada"}`)
+	}), weheat.WithCache(weheat.NewMemoryCache(), weheat.DefaultCacheTTLs()))
+	ctx := testContext(t)
+
+	for i := range 2 {
+		user, err := client.GetUserMe(ctx, weheat.RequestOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.FirstName == nil || *user.FirstName != "Ada" {
+			t.Fatalf("call %d: FirstName = %v", i, user.FirstName)
+		}
+	}
+	if requests.Load() != 2 || conditional.Load() != 1 {
+		t.Fatalf("requests = %d, conditional = %d; want a revalidation", requests.Load(), conditional.Load())
+	}
+
+	if _, err := client.GetUserMe(ctx, weheat.RequestOptions{NoCache: true}); err != nil {
+		t.Fatal(err)
+	}
+	if conditional.Load() != 1 {
+		t.Fatal("NoCache request sent If-None-Match")
+	}
+}
+
+func TestMemoryCacheEvicts(t *testing.T) {
+	cache := weheat.NewMemoryCache()
+	cache.MaxEntries = 2
+	now := time.Now()
+	cache.Set("expired", &weheat.CachedResponse{Expires: now.Add(-time.Minute)})
+	cache.Set("soon", &weheat.CachedResponse{Expires: now.Add(time.Minute)})
+	cache.Set("later", &weheat.CachedResponse{Expires: now.Add(time.Hour)})
+	if _, ok := cache.Get("expired"); ok {
+		t.Fatal("expired entry kept over the limit")
+	}
+	cache.Set("latest", &weheat.CachedResponse{Expires: now.Add(2 * time.Hour)})
+	if _, ok := cache.Get("soon"); ok {
+		t.Fatal("soonest-expiring entry kept over the limit")
+	}
+	for _, key := range []string{"later", "latest"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Fatalf("%s evicted", key)
+		}
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const timeFormat = "2006-01-02T15:04:05.000000-0700"
@@ -20,6 +26,18 @@ type Client struct {
 	httpClient  *http.Client
 	tokenSource TokenSource
 	userAgent   string
+	retryPolicy *RetryPolicy
+	limiter     *limiter
+	// maxResponseSize caps buffered response bodies; 0 means unlimited.
+	maxResponseSize int64
+	cache           *responseCache
+	flights         *flightGroup
+	middleware      []Middleware
+	doer            Doer
+	tracerProvider  trace.TracerProvider
+	meterProvider   metric.MeterProvider
+	telemetry       *telemetry
+	log             *requestLogger
 }
 
 // NewClient creates a new client with optional overrides.
@@ -40,6 +58,11 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 			return nil, err
 		}
 	}
+	client.doer = client.buildDoer()
+	client.telemetry, err = newTelemetry(client.tracerProvider, client.meterProvider)
+	if err != nil {
+		return nil, err
+	}
 
 	return client, nil
 }
@@ -47,7 +70,13 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 // GetUserMe fetches the current user profile.
 func (c *Client) GetUserMe(ctx context.Context, opts RequestOptions) (*ReadUserMe, error) {
 	var out ReadUserMe
-	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/users/me", nil, opts, &out); err != nil {
+	req := request{
+		operation: OperationGetUserMe,
+		method:    http.MethodGet,
+		path:      "/api/v1/users/me",
+		opts:      opts,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -78,7 +107,14 @@ func (c *Client) ListHeatPumps(ctx context.Context, params ListHeatPumpsParams)
 	}
 
 	var out ReadAllHeatPumpPagedResponse
-	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/heat-pumps", query, params.RequestOptions, &out); err != nil {
+	req := request{
+		operation: OperationListHeatPumps,
+		method:    http.MethodGet,
+		path:      "/api/v1/heat-pumps",
+		query:     query,
+		opts:      params.RequestOptions,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -88,7 +124,14 @@ func (c *Client) ListHeatPumps(ctx context.Context, params ListHeatPumpsParams)
 func (c *Client) GetHeatPump(ctx context.Context, heatPumpID string, opts RequestOptions) (*ReadHeatPump, error) {
 	path := fmt.Sprintf("/api/v1/heat-pumps/%s", url.PathEscape(heatPumpID))
 	var out ReadHeatPump
-	if err := c.doJSON(ctx, http.MethodGet, path, nil, opts, &out); err != nil {
+	req := request{
+		operation:  OperationGetHeatPump,
+		heatPumpID: heatPumpID,
+		method:     http.MethodGet,
+		path:       path,
+		opts:       opts,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -98,7 +141,14 @@ func (c *Client) GetHeatPump(ctx context.Context, heatPumpID string, opts Reques
 func (c *Client) GetLatestLog(ctx context.Context, heatPumpID string, opts RequestOptions) (*RawHeatPumpLog, error) {
 	path := fmt.Sprintf("/api/v1/heat-pumps/%s/logs/latest", url.PathEscape(heatPumpID))
 	var out RawHeatPumpLog
-	if err := c.doJSON(ctx, http.MethodGet, path, nil, opts, &out); err != nil {
+	req := request{
+		operation:  OperationGetLatestLog,
+		heatPumpID: heatPumpID,
+		method:     http.MethodGet,
+		path:       path,
+		opts:       opts,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -111,12 +161,59 @@ func (c *Client) GetRawLogs(ctx context.Context, heatPumpID string, query LogQue
 	applyLogQuery(values, query)
 
 	var out []RawHeatPumpLog
-	if err := c.doJSON(ctx, http.MethodGet, path, values, query.RequestOptions, &out); err != nil {
+	req := request{
+		operation:  OperationGetRawLogs,
+		heatPumpID: heatPumpID,
+		method:     http.MethodGet,
+		path:       path,
+		query:      values,
+		opts:       query.RequestOptions,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
+// StreamRawLogs returns raw log entries for a heat pump, decoding them one at
+// a time from the response body instead of buffering the whole array.
+func (c *Client) StreamRawLogs(ctx context.Context, heatPumpID string, query LogQuery) iter.Seq2[RawHeatPumpLog, error] {
+	return func(yield func(RawHeatPumpLog, error) bool) {
+		path := fmt.Sprintf("/api/v1/heat-pumps/%s/logs/raw", url.PathEscape(heatPumpID))
+		values := url.Values{}
+		applyLogQuery(values, query)
+
+		req := request{
+			operation:  OperationGetRawLogs,
+			heatPumpID: heatPumpID,
+			method:     http.MethodGet,
+			path:       path,
+			query:      values,
+			opts:       query.RequestOptions,
+			stream:     true,
+		}
+		resp, _, err := c.send(ctx, req)
+		if err != nil {
+			yield(RawHeatPumpLog{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		body := &tailReader{r: resp.Body}
+		for log, err := range decodeJSONArray[RawHeatPumpLog](body) {
+			if err != nil && err != body.err {
+				c.log.decodeFailure(ctx, req, body.tail, err)
+				if stream, ok := resp.Body.(*streamBody); ok && stream.err == nil {
+					stream.err = err
+				}
+			}
+			if !yield(log, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
 // GetLogs returns aggregated log views for a heat pump.
 func (c *Client) GetLogs(ctx context.Context, heatPumpID string, query LogQuery) ([]HeatPumpLogView, error) {
 	path := fmt.Sprintf("/api/v1/heat-pumps/%s/logs", url.PathEscape(heatPumpID))
@@ -124,7 +221,15 @@ func (c *Client) GetLogs(ctx context.Context, heatPumpID string, query LogQuery)
 	applyLogQuery(values, query)
 
 	var out []HeatPumpLogView
-	if err := c.doJSON(ctx, http.MethodGet, path, values, query.RequestOptions, &out); err != nil {
+	req := request{
+		operation:  OperationGetLogs,
+		heatPumpID: heatPumpID,
+		method:     http.MethodGet,
+		path:       path,
+		query:      values,
+		opts:       query.RequestOptions,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return out, nil
@@ -137,7 +242,15 @@ func (c *Client) GetEnergyLogs(ctx context.Context, heatPumpID string, query Ene
 	applyEnergyQuery(values, query)
 
 	var out []EnergyView
-	if err := c.doJSON(ctx, http.MethodGet, path, values, query.RequestOptions, &out); err != nil {
+	req := request{
+		operation:  OperationGetEnergyLogs,
+		heatPumpID: heatPumpID,
+		method:     http.MethodGet,
+		path:       path,
+		query:      values,
+		opts:       query.RequestOptions,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return out, nil
@@ -147,12 +260,76 @@ func (c *Client) GetEnergyLogs(ctx context.Context, heatPumpID string, query Ene
 func (c *Client) GetEnergyTotals(ctx context.Context, heatPumpID string, opts RequestOptions) (*TotalEnergyAggregate, error) {
 	path := fmt.Sprintf("/api/v1/energy-logs/%s/total", url.PathEscape(heatPumpID))
 	var out TotalEnergyAggregate
-	if err := c.doJSON(ctx, http.MethodGet, path, nil, opts, &out); err != nil {
+	req := request{
+		operation:  OperationGetEnergyTotals,
+		heatPumpID: heatPumpID,
+		method:     http.MethodGet,
+		path:       path,
+		opts:       opts,
+	}
+	if err := c.doJSON(ctx, req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
+// decodeJSONArray yields the elements of a top-level JSON array as they are
+// read. A null or empty body yields nothing.
+func decodeJSONArray[T any](r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+
+		tok, err := dec.Token()
+		if err == io.EOF || (err == nil && tok == nil) {
+			return
+		}
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			yield(zero, fmt.Errorf("weheat: expected JSON array, got %v", tok))
+			return
+		}
+		for dec.More() {
+			var item T
+			if err := dec.Decode(&item); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// tailReader keeps the last bytes read, so a streamed body that fails to
+// decode can be logged like a buffered one. err is the reader's own error,
+// which is a transport failure rather than a decode failure.
+type tailReader struct {
+	r    io.Reader
+	tail []byte
+	err  error
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.tail = append(t.tail, p[:n]...)
+	if extra := len(t.tail) - maxLoggedBody; extra > 0 {
+		t.tail = append(t.tail[:0], t.tail[extra:]...)
+	}
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return n, err
+}
+
 func applyLogQuery(values url.Values, query LogQuery) {
 	if query.StartTime != nil {
 		values.Set("startTime", query.StartTime.UTC().Format(timeFormat))
@@ -177,42 +354,287 @@ func applyEnergyQuery(values url.Values, query EnergyLogQuery) {
 	}
 }
 
-func (c *Client) doJSON(ctx context.Context, method string, path string, query url.Values, opts RequestOptions, out any) error {
+// request describes a single logical API call.
+type request struct {
+	operation  Operation
+	heatPumpID string
+	method     string
+	path       string
+	query      url.Values
+	opts       RequestOptions
+	// stream leaves the successful response body unread for the caller.
+	stream bool
+	// ifNoneMatch makes the request conditional; a 304 reply is then not an error.
+	ifNoneMatch string
+}
+
+// fetched is a buffered response body and how it was obtained; coalesced
+// callers share one.
+type fetched struct {
+	body     []byte
+	status   int
+	attempts int
+	cacheHit bool
+}
+
+// doJSON records the whole operation, so calls answered from the cache or by
+// another caller's request are traced and measured too.
+func (c *Client) doJSON(ctx context.Context, r request, out any) error {
+	ctx, finish := c.telemetry.start(ctx, r)
+	fetch := func(ctx context.Context) (fetched, error) {
+		if ttl, ok := c.cache.ttl(r); ok {
+			return c.sendCached(ctx, r, ttl)
+		}
+		result, _, err := c.fetch(ctx, r)
+		return result, err
+	}
+
+	var result fetched
+	var coalesced bool
+	var err error
+	if c.flights != nil && r.method == http.MethodGet {
+		key := cacheKey(r)
+		if r.opts.NoCache {
+			key += " no-cache"
+		}
+		result, coalesced, err = c.flights.do(ctx, key, fetch)
+	} else {
+		result, err = fetch(ctx)
+	}
+	if err == nil {
+		err = c.decode(ctx, r, result.body, out)
+	}
+	o := outcome{status: result.status, cacheHit: result.cacheHit, coalesced: coalesced, err: err}
+	if !coalesced {
+		o.attempts = result.attempts
+	}
+	finish(o)
+	return err
+}
+
+func (c *Client) decode(ctx context.Context, r request, body []byte, out any) error {
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		c.log.decodeFailure(ctx, r, body, err)
+		return err
+	}
+	return nil
+}
+
+// fetch performs a buffered request inside an operation doJSON is already
+// recording.
+func (c *Client) fetch(ctx context.Context, r request) (fetched, *http.Response, error) {
+	resp, body, attempts, err := c.sendAttempts(ctx, r)
+	result := fetched{body: body, attempts: attempts}
+	if resp != nil {
+		result.status = resp.StatusCode
+	}
+	return result, resp, err
+}
+
+// send performs the request, retrying idempotent calls according to the
+// client's retry policy, and records it as an operation. Unless r.stream is
+// set, the body is read before the attempt counts as successful and the
+// response body is already closed.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, []byte, error) {
+	ctx, finish := c.telemetry.start(ctx, r)
+	resp, body, attempts, err := c.sendAttempts(ctx, r)
+	if r.stream && err == nil {
+		// A streamed operation lasts until its body is closed.
+		resp.Body = &streamBody{ReadCloser: resp.Body, finish: func(err error) {
+			finish(outcome{status: resp.StatusCode, attempts: attempts, err: err})
+		}}
+		return resp, body, nil
+	}
+	o := outcome{attempts: attempts, err: err}
+	if resp != nil {
+		o.status = resp.StatusCode
+	}
+	finish(o)
+	return resp, body, err
+}
+
+// streamBody ends a streamed operation's telemetry on Close, reporting the
+// first read error or the decode error set in err.
+type streamBody struct {
+	io.ReadCloser
+	finish func(error)
+	err    error
+	once   sync.Once
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.finish(b.err) })
+	return err
+}
+
+func (c *Client) sendAttempts(ctx context.Context, r request) (*http.Response, []byte, int, error) {
 	headers := map[string]string{
 		"Accept": "application/json, text/json, text/plain",
 	}
-	applyRequestOptions(headers, opts)
+	applyRequestOptions(headers, r.opts)
+	if r.ifNoneMatch != "" {
+		headers["If-None-Match"] = r.ifNoneMatch
+	}
 
-	req, err := c.newRequest(ctx, method, path, query, headers)
-	if err != nil {
-		return err
+	for attempt := 1; ; attempt++ {
+		resp, body, err := c.sendOnce(ctx, r, headers, attempt)
+		if err == nil {
+			return resp, body, attempt, nil
+		}
+		retry, retryable := err.(*retryableError)
+		if retryable {
+			err = retry.err
+		}
+		if c.retryPolicy == nil {
+			return nil, nil, attempt, err
+		}
+		if !retryable || !isIdempotent(r.method) || attempt >= c.retryPolicy.MaxAttempts || ctx.Err() != nil {
+			return nil, nil, attempt, retryFailure(attempt, err)
+		}
+		delay := c.retryPolicy.backoff(attempt)
+		if retry.after > 0 {
+			// A server asking for a longer pause than the policy allows is
+			// not worth waiting for; report the failure instead.
+			if limit := c.retryPolicy.MaxBackoff; limit > 0 && retry.after > limit {
+				return nil, nil, attempt, retryFailure(attempt, err)
+			}
+			delay = retry.after
+		}
+		if !waitRetry(ctx, delay) {
+			return nil, nil, attempt, retryFailure(attempt, err)
+		}
+	}
+}
+
+// retryableError marks an attempt failure that may succeed when retried.
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (c *Client) sendOnce(ctx context.Context, r request, headers map[string]string, attempt int) (*http.Response, []byte, error) {
+	release := func() {}
+	if c.limiter != nil {
+		var err error
+		release, err = c.limiter.acquire(ctx, r.operation)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			release()
+		}
+	}()
 
-	resp, err := c.httpClient.Do(req)
+	ctx = withCallInfo(ctx, CallInfo{Operation: r.operation, HeatPumpID: r.heatPumpID, Attempt: attempt})
+	req, err := c.newRequest(ctx, r.method, r.path, r.query, headers)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	c.log.request(ctx, req, attempt)
+	start := time.Now()
+	resp, err := c.doer.Do(req)
 	if err != nil {
-		return err
+		c.log.response(ctx, req, nil, nil, time.Since(start), err)
+		return nil, nil, &retryableError{err: err}
+	}
+
+	if resp.StatusCode == http.StatusNotModified && r.ifNoneMatch != "" {
+		resp.Body.Close()
+		c.log.response(ctx, req, resp, nil, time.Since(start), nil)
+		return resp, nil, nil
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return &APIError{StatusCode: resp.StatusCode, Body: body}
+		defer resp.Body.Close()
+		body, err := c.readBody(resp.Body)
+		if err != nil && !errors.Is(err, ErrResponseTooLarge) {
+			c.log.response(ctx, req, nil, nil, time.Since(start), err)
+			return nil, nil, &retryableError{err: err}
+		}
+		c.log.response(ctx, req, resp, body, time.Since(start), nil)
+		apiErr := newAPIError(resp, body)
+		if !isRetryableStatus(resp.StatusCode) {
+			return nil, nil, apiErr
+		}
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return nil, nil, &retryableError{err: apiErr, after: retryAfter}
 	}
 
-	if out == nil || len(body) == 0 {
-		return nil
+	if r.stream {
+		c.log.response(ctx, req, resp, nil, time.Since(start), nil)
+		handedOff = true
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+		return resp, nil, nil
 	}
 
-	dec := json.NewDecoder(bytes.NewReader(body))
-	dec.UseNumber()
-	if err := dec.Decode(out); err != nil {
+	defer resp.Body.Close()
+	body, err := c.readBody(resp.Body)
+	c.log.response(ctx, req, resp, nil, time.Since(start), err)
+	if err != nil {
+		if errors.Is(err, ErrResponseTooLarge) {
+			return nil, nil, err
+		}
+		return nil, nil, &retryableError{err: err}
+	}
+	return resp, body, nil
+}
+
+// readBody reads a response body, enforcing the client's size limit.
+func (c *Client) readBody(body io.Reader) ([]byte, error) {
+	if c.maxResponseSize <= 0 {
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, c.maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > c.maxResponseSize {
+		return data[:c.maxResponseSize], ErrResponseTooLarge
+	}
+	return data, nil
+}
+
+// releasingBody frees the request's limiter slot once a streamed body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func retryFailure(attempts int, err error) error {
+	if attempts <= 1 {
 		return err
 	}
-	return nil
+	return &RetryError{Attempts: attempts, Err: err}
 }
 
 func (c *Client) newRequest(ctx context.Context, method string, path string, query url.Values, headers map[string]string) (*http.Request, error) {
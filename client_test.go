@@ -0,0 +1,231 @@
+package weheat_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+// defaultPumpID is the heat pump weheattest.Server simulates by default.
+const defaultPumpID = "5c4f1a52-8d0e-4b7a-9f55-0d2f4a7c9e31"
+
+// newTestClient returns a client for handler with retries disabled.
+func newTestClient(t *testing.T, handler http.Handler, opts ...weheat.ClientOption) *weheat.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	opts = append([]weheat.ClientOption{
+		weheat.WithBaseURL(srv.URL),
+		weheat.WithTokenSource(weheat.StaticToken("token")),
+		weheat.WithRetryPolicy(weheat.RetryPolicy{MaxAttempts: 1}),
+	}, opts...)
+	client, err := weheat.NewClient(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestStreamRawLogsLogsDecodeFailure(t *testing.T) {
+	var logs bytes.Buffer
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`[{"heatPumpId":"hp","timestamp":"2024-01-01T00:00:00Z"},{"heatPumpId":`))
+	}), weheat.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	var decoded int
+	var streamErr error
+	for _, err := range client.StreamRawLogs(testContext(t), "hp", weheat.LogQuery{}) {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		decoded++
+	}
+	if decoded != 1 || streamErr == nil {
+		t.Fatalf("decoded %d logs, err %v; want 1 and a decode error", decoded, streamErr)
+	}
+	if out := logs.String(); !strings.Contains(out, "weheat: decode failed") || !strings.Contains(out, "operation=GetRawLogs") {
+		t.Fatalf("decode failure not logged:\n%s", out)
+	}
+}
+
+func TestStreamRawLogsMatchesBufferedCall(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	srv := weheattest.NewServer(weheattest.ServerConfig{Clock: func() time.Time { return now }})
+	defer srv.Close()
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t)
+	id := defaultPumpID
+	start := now.Add(-time.Hour)
+	query := weheat.LogQuery{StartTime: &start, EndTime: &now}
+
+	buffered, err := client.GetRawLogs(ctx, id, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []weheat.RawHeatPumpLog
+	for log, err := range client.StreamRawLogs(ctx, id, query) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed = append(streamed, log)
+	}
+	if len(streamed) == 0 || len(streamed) != len(buffered) {
+		t.Fatalf("streamed %d logs, buffered %d", len(streamed), len(buffered))
+	}
+	for i := range streamed {
+		if !streamed[i].Timestamp.Equal(buffered[i].Timestamp) {
+			t.Fatalf("log %d: %v != %v", i, streamed[i].Timestamp, buffered[i].Timestamp)
+		}
+	}
+
+	// Stopping early closes the stream without error.
+	for range client.StreamRawLogs(ctx, id, query) {
+		break
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	var calls atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+		fmt.Fprintf(w, `[{"heatPumpId":"hp","timestamp":"2024-01-01T00:00:00Z"}%s]`, strings.Repeat(`,{"heatPumpId":"hp","timestamp":"2024-01-01T00:00:00Z"}`, 20))
+	}), weheat.WithMaxResponseSize(64), weheat.WithRetryPolicy(weheat.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+	ctx := testContext(t)
+
+	// An oversized success is not retried: the next attempt would be as big.
+	if _, err := client.GetRawLogs(ctx, "hp", weheat.LogQuery{}); !errors.Is(err, weheat.ErrResponseTooLarge) {
+		t.Fatalf("err = %v, want ErrResponseTooLarge", err)
+	}
+	if got := calls.Swap(0); got != 1 {
+		t.Errorf("oversized success made %d attempts, want 1", got)
+	}
+
+	// Streaming is not limited.
+	var streamed int
+	for _, err := range client.StreamRawLogs(ctx, "hp", weheat.LogQuery{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed++
+	}
+	if streamed != 21 {
+		t.Errorf("streamed %d logs, want 21", streamed)
+	}
+	calls.Store(0)
+
+	// An oversized error body is truncated and the status still retried.
+	status.Store(http.StatusServiceUnavailable)
+	_, err := client.GetRawLogs(ctx, "hp", weheat.LogQuery{})
+	var apiErr *weheat.APIError
+	if !errors.Is(err, weheat.ErrServerError) || !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want a 503 APIError", err)
+	}
+	if len(apiErr.Body) != 64 {
+		t.Errorf("error body is %d bytes, want truncated to 64", len(apiErr.Body))
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("oversized 503 made %d attempts, want 3", got)
+	}
+}
+
+func TestListHeatPumpsFiltersByOrganisation(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{Pumps: []*weheattest.SimulatedPump{
+		{ID: "a", OrganisationID: "org-1"},
+		{ID: "b", OrganisationID: "org-2"},
+		{ID: "c", OrganisationID: "org-1"},
+	}})
+	defer srv.Close()
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := client.ListHeatPumps(testContext(t), weheat.ListHeatPumpsParams{OrganisationID: "org-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 2 || page.Data[0].ID != "a" || page.Data[1].ID != "c" {
+		t.Fatalf("pumps = %+v, want a and c", page.Data)
+	}
+}
+
+func TestEnergyTotalsCoverWholeHistory(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	var clock atomic.Pointer[time.Time]
+	clock.Store(&now)
+	pump := &weheattest.SimulatedPump{
+		ID:             "old",
+		HasDHW:         true,
+		CommissionedAt: now.AddDate(0, -4, 0),
+		Interval:       15 * time.Minute,
+	}
+	srv := weheattest.NewServer(weheattest.ServerConfig{
+		Pumps: []*weheattest.SimulatedPump{pump},
+		Clock: func() time.Time { return *clock.Load() },
+	})
+	defer srv.Close()
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t)
+
+	// Totals grow from the first sample on, and stay right as days pass.
+	for _, at := range []time.Time{now, now.Add(36 * time.Hour)} {
+		clock.Store(&at)
+		totals, err := client.GetEnergyTotals(ctx, pump.ID, weheat.RequestOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wantIn, wantOut float64
+		for _, year := range weheat.AggregateEnergyLogs(pump.Logs(pump.CommissionedAt, at), weheat.EnergyIntervalYear) {
+			wantIn += year.TotalEInHeating + year.TotalEInDHW
+			wantOut += year.TotalEOutHeating + year.TotalEOutDHW
+		}
+		gotIn := *totals.TotalEInHeating + *totals.TotalEInDHW
+		gotOut := *totals.TotalEOutHeating + *totals.TotalEOutDHW
+		if wantIn == 0 || math.Abs(gotIn-wantIn) > 1e-6*wantIn || math.Abs(gotOut-wantOut) > 1e-6*wantOut {
+			t.Fatalf("at %v totals in/out = %.3f/%.3f, want %.3f/%.3f", at, gotIn, gotOut, wantIn, wantOut)
+		}
+	}
+}
+
+// countRequests is middleware counting HTTP attempts per operation.
+func countRequests(counts *sync.Map) weheat.Middleware {
+	return func(next weheat.Doer) weheat.Doer {
+		return weheat.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			info, _ := weheat.CallInfoFromContext(req.Context())
+			n, _ := counts.LoadOrStore(info.Operation, new(atomic.Int32))
+			n.(*atomic.Int32).Add(1)
+			return next.Do(req)
+		})
+	}
+}
+
+func requestCount(counts *sync.Map, op weheat.Operation) int {
+	n, ok := counts.Load(op)
+	if !ok {
+		return 0
+	}
+	return int(n.(*atomic.Int32).Load())
+}
+
+func intPtr(v int) *int { return &v }
@@ -0,0 +1,72 @@
+package weheat
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup lets concurrent identical requests share one round-trip.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	wait    WaitRecorder
+	result  fetched
+	err     error
+}
+
+// do runs fetch once per key at a time. The shared fetch is detached from
+// the first caller's cancellation so one caller giving up does not fail the
+// others; each caller still returns as soon as its own context ends, and the
+// fetch is cancelled once every caller has gone. It otherwise runs with the
+// first caller's context values, such as its trace span. Limiter waits are
+// reported to the WaitRecorder of every caller still waiting at the end.
+// shared reports whether the caller joined a fetch started by another.
+func (g *flightGroup) do(ctx context.Context, key string, fetch func(context.Context) (fetched, error)) (result fetched, shared bool, err error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	f, shared := g.flights[key]
+	if !shared {
+		f = &flight{done: make(chan struct{})}
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f.cancel = cancel
+		g.flights[key] = f
+		go func() {
+			defer cancel()
+			f.result, f.err = fetch(WithWaitRecorder(fetchCtx, &f.wait))
+			g.mu.Lock()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		waitRecorderFrom(ctx).add(f.wait.Total())
+		return f.result, shared, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody is left to use the result; later callers start afresh.
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+			f.cancel()
+		}
+		g.mu.Unlock()
+		return fetched{}, shared, ctx.Err()
+	}
+}
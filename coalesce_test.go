@@ -0,0 +1,114 @@
+package weheat_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+func TestCoalescingSharesOneRequest(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		fmt.Fprint(w, `{"id":"hp","name":"Garage"}`)
+	}), weheat.WithRequestCoalescing())
+	ctx := testContext(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pump, err := client.GetHeatPump(ctx, "hp", weheat.RequestOptions{})
+			if err == nil && pump.ID != "hp" {
+				err = fmt.Errorf("ID = %q", pump.ID)
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestCoalescingCancelsWhenEveryCallerLeaves(t *testing.T) {
+	cancelled := make(chan struct{})
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+	}), weheat.WithRequestCoalescing())
+
+	ctx, cancel := context.WithTimeout(testContext(t), 100*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetHeatPump(ctx, "hp", weheat.RequestOptions{}); err == nil {
+				t.Error("want error after deadline")
+			}
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shared request kept running after every caller left")
+	}
+}
+
+func TestCoalescingReportsWaitToEveryCaller(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			<-release
+		}
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}), weheat.WithRequestCoalescing(), weheat.WithRateLimit(weheat.RateLimit{Rate: 5, Burst: 1}))
+	ctx := testContext(t)
+
+	// Spend the burst so the shared request waits on the limiter.
+	if _, err := client.GetHeatPump(ctx, "warm-up", weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	recorders := []*weheat.WaitRecorder{{}, {}}
+	var wg sync.WaitGroup
+	for _, rec := range recorders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetHeatPump(weheat.WithWaitRecorder(ctx, rec), "hp", weheat.RequestOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(300 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for i, rec := range recorders {
+		if rec.Total() <= 0 {
+			t.Errorf("caller %d recorded no limiter wait", i)
+		}
+	}
+}
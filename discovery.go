@@ -25,51 +25,36 @@ func (h HeatPumpInfo) ReadableName() string {
 
 // DiscoverActiveHeatPumps lists active heat pumps available to the account.
 func (c *Client) DiscoverActiveHeatPumps(ctx context.Context) ([]HeatPumpInfo, error) {
-	page := 1
 	pageSize := 1000
 	state := DeviceStateActive
 
 	var out []HeatPumpInfo
-	for {
-		resp, err := c.ListHeatPumps(ctx, ListHeatPumpsParams{
-			Page:     &page,
-			PageSize: &pageSize,
-			State:    &state,
-		})
+	pumps := c.HeatPumps(ctx, ListHeatPumpsParams{
+		PageSize: &pageSize,
+		State:    &state,
+	})
+	for pump, err := range pumps {
 		if err != nil {
 			return nil, err
 		}
-		if resp != nil {
-			for _, pump := range resp.Data {
-				info := HeatPumpInfo{
-					ID:           pump.ID,
-					SerialNumber: pump.SerialNumber,
-				}
-				if pump.Name != nil {
-					info.DeviceName = *pump.Name
-				}
-				if pump.Model != nil {
-					model := *pump.Model
-					info.Model = &model
-					info.ModelName = HeatPumpModelName(model)
-				} else {
-					info.ModelName = "Unknown"
-				}
-				if pump.DHWType != nil && *pump.DHWType == DhwTypeAvailable {
-					info.HasDHW = true
-				}
-				out = append(out, info)
-			}
+		info := HeatPumpInfo{
+			ID:           pump.ID,
+			SerialNumber: pump.SerialNumber,
 		}
-
-		if resp == nil || resp.Metadata == nil || resp.Metadata.TotalPages == nil {
-			break
+		if pump.Name != nil {
+			info.DeviceName = *pump.Name
+		}
+		if pump.Model != nil {
+			model := *pump.Model
+			info.Model = &model
+			info.ModelName = HeatPumpModelName(model)
+		} else {
+			info.ModelName = "Unknown"
 		}
-		totalPages := *resp.Metadata.TotalPages
-		if page >= totalPages {
-			break
+		if pump.DHWType != nil && *pump.DHWType == DhwTypeAvailable {
+			info.HasDHW = true
 		}
-		page++
+		out = append(out, info)
 	}
 
 	return out, nil
@@ -1,21 +1,142 @@
 package weheat
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 )
 
 var ErrClientMissing = errors.New("weheat: client required")
 
+// ErrResponseTooLarge is returned when a buffered response exceeds the limit
+// set with WithMaxResponseSize.
+var ErrResponseTooLarge = errors.New("weheat: response body too large")
+
+// Sentinel errors matched by APIError via errors.Is.
+var (
+	ErrBadRequest   = errors.New("weheat: bad request")
+	ErrUnauthorized = errors.New("weheat: unauthorized")
+	ErrForbidden    = errors.New("weheat: forbidden")
+	ErrNotFound     = errors.New("weheat: not found")
+	ErrRateLimited  = errors.New("weheat: rate limited")
+	ErrServerError  = errors.New("weheat: server error")
+)
+
 // APIError represents a non-2xx response from the Weheat API.
 type APIError struct {
 	StatusCode int
 	Body       []byte
+	Method     string
+	URL        string
+	Header     http.Header
+	// Problem holds the parsed problem-details body, if the API returned one.
+	Problem *ProblemDetails
+}
+
+// ProblemDetails mirrors the RFC 7807 / ASP.NET ProblemDetails body.
+type ProblemDetails struct {
+	Type     string              `json:"type,omitempty"`
+	Title    string              `json:"title,omitempty"`
+	Status   int                 `json:"status,omitempty"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	TraceID  string              `json:"traceId,omitempty"`
+	Errors   map[string][]string `json:"errors,omitempty"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		Header:     resp.Header,
+		Problem:    parseProblemDetails(body),
+	}
+	if resp.Request != nil {
+		apiErr.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			apiErr.URL = resp.Request.URL.String()
+		}
+	}
+	return apiErr
+}
+
+func parseProblemDetails(body []byte) *ProblemDetails {
+	if len(body) == 0 || body[0] != '{' {
+		return nil
+	}
+	var problem ProblemDetails
+	if err := json.Unmarshal(body, &problem); err != nil {
+		return nil
+	}
+	if problem.Title == "" && problem.Detail == "" && problem.Type == "" && len(problem.Errors) == 0 {
+		return nil
+	}
+	return &problem
 }
 
 func (e *APIError) Error() string {
+	prefix := fmt.Sprintf("weheat: api error %d", e.StatusCode)
+	if e.Method != "" && e.URL != "" {
+		prefix = fmt.Sprintf("weheat: %s %s: api error %d", e.Method, e.URL, e.StatusCode)
+	}
+	if e.Problem != nil {
+		return prefix + ": " + e.Problem.summary()
+	}
 	if len(e.Body) == 0 {
-		return fmt.Sprintf("weheat: api error %d", e.StatusCode)
+		return prefix
+	}
+	return fmt.Sprintf("%s: %s", prefix, string(e.Body))
+}
+
+// Is reports whether the error matches one of the status sentinels.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= 500
+	default:
+		return false
+	}
+}
+
+// TraceID returns the backend trace ID from the problem body, if present.
+func (e *APIError) TraceID() string {
+	if e.Problem == nil {
+		return ""
+	}
+	return e.Problem.TraceID
+}
+
+func (p *ProblemDetails) summary() string {
+	parts := make([]string, 0, 3)
+	if p.Title != "" {
+		parts = append(parts, p.Title)
+	}
+	if p.Detail != "" {
+		parts = append(parts, p.Detail)
+	}
+	fields := make([]string, 0, len(p.Errors))
+	for field := range p.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		parts = append(parts, fmt.Sprintf("%s: %s", field, strings.Join(p.Errors[field], "; ")))
+	}
+	if p.TraceID != "" {
+		parts = append(parts, "trace "+p.TraceID)
 	}
-	return fmt.Sprintf("weheat: api error %d: %s", e.StatusCode, string(e.Body))
+	return strings.Join(parts, ": ")
 }
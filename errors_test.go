@@ -0,0 +1,42 @@
+package weheat_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+func TestAPIErrorSentinels(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, weheat.ErrBadRequest},
+		{http.StatusUnauthorized, weheat.ErrUnauthorized},
+		{http.StatusForbidden, weheat.ErrForbidden},
+		{http.StatusNotFound, weheat.ErrNotFound},
+		{http.StatusTooManyRequests, weheat.ErrRateLimited},
+		{http.StatusInternalServerError, weheat.ErrServerError},
+	} {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(tc.status)
+			fmt.Fprintf(w, `{"title":"failed","status":%d,"traceId":"trace-%d"}`, tc.status, tc.status)
+		}))
+		_, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("status %d: err = %v, want %v", tc.status, err, tc.want)
+			continue
+		}
+		var apiErr *weheat.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status || apiErr.TraceID() != fmt.Sprintf("trace-%d", tc.status) {
+			t.Errorf("status %d: APIError = %+v", tc.status, apiErr)
+		}
+		if tc.want != weheat.ErrNotFound && errors.Is(err, weheat.ErrNotFound) {
+			t.Errorf("status %d matched ErrNotFound", tc.status)
+		}
+	}
+}
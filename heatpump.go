@@ -1,11 +1,19 @@
 package weheat
 
-import "context"
-
-// HeatPump provides convenience accessors for heat pump telemetry.
+import (
+	"context"
+	"sync"
+)
+
+// HeatPump provides convenience accessors for heat pump telemetry. It is safe
+// for concurrent use: refreshes replace the stored log and totals rather than
+// modifying them. Log, EnergyTotals and Snapshot return copies; values
+// returned by the metric accessors are shared and must not be modified.
 type HeatPump struct {
-	client          *Client
-	id              string
+	client *Client
+	id     string
+
+	mu              sync.RWMutex
 	lastLog         *RawHeatPumpLog
 	energyTotals    *TotalEnergyAggregate
 	nominalMaxPower *float64
@@ -22,18 +30,20 @@ func (h *HeatPump) ID() string {
 	return h.id
 }
 
-// Log returns the most recently fetched log entry.
+// Log returns a copy of the most recently fetched log entry.
 func (h *HeatPump) Log() *RawHeatPumpLog {
-	return h.lastLog
+	return cloneStruct(h.log())
 }
 
-// EnergyTotals returns the most recently fetched energy totals.
+// EnergyTotals returns a copy of the most recently fetched energy totals.
 func (h *HeatPump) EnergyTotals() *TotalEnergyAggregate {
-	return h.energyTotals
+	return cloneStruct(h.energy())
 }
 
 // NominalMaxPower returns the nominal max power if known.
 func (h *HeatPump) NominalMaxPower() *float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.nominalMaxPower
 }
 
@@ -50,14 +60,16 @@ func (h *HeatPump) RefreshLogs(ctx context.Context, opts RequestOptions) error {
 	if h.client == nil {
 		return ErrClientMissing
 	}
-	if h.nominalMaxPower == nil {
+	if h.NominalMaxPower() == nil {
 		_ = h.loadNominalMaxPower(ctx, opts)
 	}
 	log, err := h.client.GetLatestLog(ctx, h.id, opts)
 	if err != nil {
 		return err
 	}
+	h.mu.Lock()
 	h.lastLog = log
+	h.mu.Unlock()
 	return nil
 }
 
@@ -70,7 +82,9 @@ func (h *HeatPump) RefreshEnergy(ctx context.Context, opts RequestOptions) error
 	if err != nil {
 		return err
 	}
+	h.mu.Lock()
 	h.energyTotals = totals
+	h.mu.Unlock()
 	return nil
 }
 
@@ -81,9 +95,11 @@ func (h *HeatPump) loadNominalMaxPower(ctx context.Context, opts RequestOptions)
 	}
 	if details.Model != nil {
 		model := *details.Model
-		h.model = &model
 		value := nominalMaxPowerForModel(model)
+		h.mu.Lock()
+		h.model = &model
 		h.nominalMaxPower = &value
+		h.mu.Unlock()
 	}
 	return nil
 }
@@ -105,6 +121,8 @@ func (h *HeatPump) log() *RawHeatPumpLog {
 	if h == nil {
 		return nil
 	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.lastLog
 }
 
@@ -112,6 +130,8 @@ func (h *HeatPump) energy() *TotalEnergyAggregate {
 	if h == nil {
 		return nil
 	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.energyTotals
 }
 
@@ -207,8 +227,12 @@ func (h *HeatPump) DHWBottomTemperature() *float64 {
 }
 
 func (h *HeatPump) COP() *float64 {
-	input := h.PowerInput()
-	output := h.PowerOutput()
+	log := h.log()
+	if log == nil {
+		return nil
+	}
+	input := intToFloat(log.CMMassPowerIn)
+	output := intToFloat(log.CMMassPowerOut)
 	if input == nil || output == nil {
 		return nil
 	}
@@ -256,14 +280,13 @@ func (h *HeatPump) IndoorUnitElectricHeaterState() *bool {
 }
 
 func (h *HeatPump) CompressorPercentage() *int {
-	if h.nominalMaxPower == nil {
-		return nil
-	}
-	log := h.log()
-	if log == nil || log.RPM == nil {
+	h.mu.RLock()
+	log, nominalMaxPower := h.lastLog, h.nominalMaxPower
+	h.mu.RUnlock()
+	if nominalMaxPower == nil || log == nil || log.RPM == nil {
 		return nil
 	}
-	value := int((100.0 / *h.nominalMaxPower) * *log.RPM)
+	value := int((100.0 / *nominalMaxPower) * *log.RPM)
 	return &value
 }
 
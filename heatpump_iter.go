@@ -0,0 +1,76 @@
+package weheat
+
+import (
+	"context"
+	"iter"
+)
+
+type heatPumpPage struct {
+	resp *ReadAllHeatPumpPagedResponse
+	err  error
+}
+
+// HeatPumps iterates over every heat pump matching params, walking the pages
+// reported in PaginationMetadata. Iteration starts at params.Page (default 1)
+// and stops at the first error. With params.Prefetch set, the next page is
+// requested while the current one is being consumed.
+func (c *Client) HeatPumps(ctx context.Context, params ListHeatPumpsParams) iter.Seq2[ReadAllHeatPump, error] {
+	return func(yield func(ReadAllHeatPump, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		fetch := func(page int) heatPumpPage {
+			p := params
+			p.Page = &page
+			resp, err := c.ListHeatPumps(ctx, p)
+			return heatPumpPage{resp: resp, err: err}
+		}
+
+		page := 1
+		if params.Page != nil {
+			page = *params.Page
+		}
+		current := fetch(page)
+		for {
+			if current.err != nil {
+				yield(ReadAllHeatPump{}, current.err)
+				return
+			}
+			more := hasMorePages(current.resp, page)
+
+			var next chan heatPumpPage
+			if more && params.Prefetch {
+				next = make(chan heatPumpPage, 1)
+				go func(page int) {
+					next <- fetch(page)
+				}(page + 1)
+			}
+
+			for _, pump := range current.resp.Data {
+				if !yield(pump, nil) {
+					return
+				}
+			}
+			if !more {
+				return
+			}
+
+			page++
+			if next != nil {
+				current = <-next
+			} else {
+				current = fetch(page)
+			}
+		}
+	}
+}
+
+func hasMorePages(resp *ReadAllHeatPumpPagedResponse, page int) bool {
+	if resp == nil || len(resp.Data) == 0 {
+		return false
+	}
+	if resp.Metadata == nil || resp.Metadata.TotalPages == nil {
+		return false
+	}
+	return page < *resp.Metadata.TotalPages
+}
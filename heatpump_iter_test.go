@@ -0,0 +1,40 @@
+package weheat_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+func TestHeatPumpsWalksEveryPage(t *testing.T) {
+	var pumps []*weheattest.SimulatedPump
+	for i := range 7 {
+		pumps = append(pumps, &weheattest.SimulatedPump{ID: fmt.Sprintf("hp-%d", i), State: weheat.DeviceStateActive})
+	}
+	srv := weheattest.NewServer(weheattest.ServerConfig{Pumps: pumps})
+	defer srv.Close()
+
+	for _, prefetch := range []bool{false, true} {
+		var counts sync.Map
+		client, err := srv.NewClient(weheat.WithMiddleware(countRequests(&counts)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for pump, err := range client.HeatPumps(testContext(t), weheat.ListHeatPumpsParams{PageSize: intPtr(3), Prefetch: prefetch}) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, pump.ID)
+		}
+		if len(ids) != 7 || ids[0] != "hp-0" || ids[6] != "hp-6" {
+			t.Errorf("prefetch=%v: ids = %v", prefetch, ids)
+		}
+		if got := requestCount(&counts, weheat.OperationListHeatPumps); got != 3 {
+			t.Errorf("prefetch=%v: %d page requests, want 3", prefetch, got)
+		}
+	}
+}
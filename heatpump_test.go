@@ -0,0 +1,97 @@
+package weheat_test
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+func newSimulatedHeatPump(t *testing.T) *weheat.HeatPump {
+	t.Helper()
+	// Just past midnight, so energy totals have little of the day to integrate.
+	now := time.Date(2024, 1, 15, 0, 30, 0, 0, time.UTC)
+	srv := weheattest.NewServer(weheattest.ServerConfig{Clock: func() time.Time { return now }})
+	t.Cleanup(srv.Close)
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return weheat.NewHeatPump(client, defaultPumpID)
+}
+
+// Run with -race: refreshes swap the stored log and totals while readers
+// compute metrics from them.
+func TestHeatPumpConcurrentRefreshAndReads(t *testing.T) {
+	hp := newSimulatedHeatPump(t)
+	ctx := testContext(t)
+
+	var done atomic.Bool
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !done.Load() {
+				runtime.Gosched()
+				snapshot := hp.Snapshot()
+				if snapshot.HeatPumpID != defaultPumpID {
+					t.Errorf("snapshot ID = %q", snapshot.HeatPumpID)
+					return
+				}
+				hp.COP()
+				hp.PowerInput()
+				hp.PowerOutput()
+				hp.EnergyTotal()
+				hp.HeatPumpState()
+				if log := hp.Log(); log != nil {
+					log.TWaterIn = nil
+				}
+				if totals := hp.EnergyTotals(); totals != nil {
+					totals.TotalEInHeating = nil
+				}
+			}
+		}()
+	}
+	for range 10 {
+		if err := hp.RefreshStatus(ctx, weheat.RequestOptions{}); err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	done.Store(true)
+	wg.Wait()
+
+	if snapshot := hp.Snapshot(); snapshot.COP == nil || snapshot.EnergyTotal == nil {
+		t.Fatalf("snapshot after refresh = %+v", snapshot)
+	}
+}
+
+func TestHeatPumpLogAndEnergyTotalsAreCopies(t *testing.T) {
+	hp := newSimulatedHeatPump(t)
+	if err := hp.RefreshStatus(testContext(t), weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := hp.Log()
+	inlet := *log.TWaterIn
+	*log.TWaterIn = -100
+	log.CMMassPowerIn = nil
+	if got := hp.WaterInletTemperature(); got == nil || *got != inlet {
+		t.Errorf("WaterInletTemperature = %v after modifying Log(), want %v", got, inlet)
+	}
+	if hp.Log().CMMassPowerIn == nil {
+		t.Error("clearing a field of Log() cleared the stored log")
+	}
+
+	totals := hp.EnergyTotals()
+	heating := *totals.TotalEInHeating
+	*totals.TotalEInHeating = -1
+	if got := hp.EnergyInHeating(); got == nil || *got != heating {
+		t.Errorf("EnergyInHeating = %v after modifying EnergyTotals(), want %v", got, heating)
+	}
+}
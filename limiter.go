@@ -0,0 +1,159 @@
+package weheat
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit describes a token bucket that allows Rate requests per second
+// with bursts of up to Burst requests.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l RateLimit) validate() error {
+	if l.Rate <= 0 {
+		return errors.New("weheat: rate limit must be positive")
+	}
+	if l.Burst < 1 {
+		return errors.New("weheat: rate limit burst must be at least 1")
+	}
+	return nil
+}
+
+// WaitRecorder accumulates the time requests spent waiting on the client's
+// rate limiter and concurrency cap.
+type WaitRecorder struct {
+	total atomic.Int64
+}
+
+// Total returns the accumulated wait time.
+func (w *WaitRecorder) Total() time.Duration {
+	if w == nil {
+		return 0
+	}
+	return time.Duration(w.total.Load())
+}
+
+func (w *WaitRecorder) add(d time.Duration) {
+	if w != nil && d > 0 {
+		w.total.Add(int64(d))
+	}
+}
+
+type waitRecorderKey struct{}
+
+// WithWaitRecorder returns a context whose requests report limiter wait time to rec.
+func WithWaitRecorder(ctx context.Context, rec *WaitRecorder) context.Context {
+	return context.WithValue(ctx, waitRecorderKey{}, rec)
+}
+
+func waitRecorderFrom(ctx context.Context) *WaitRecorder {
+	rec, _ := ctx.Value(waitRecorderKey{}).(*WaitRecorder)
+	return rec
+}
+
+// limiter combines a global token bucket, per-operation overrides and a cap
+// on in-flight requests.
+type limiter struct {
+	global    *tokenBucket
+	endpoints map[Operation]*tokenBucket
+	slots     chan struct{}
+}
+
+func (c *Client) ensureLimiter() *limiter {
+	if c.limiter == nil {
+		c.limiter = &limiter{endpoints: map[Operation]*tokenBucket{}}
+	}
+	return c.limiter
+}
+
+// acquire blocks until the request may be sent. The returned func releases
+// the concurrency slot once the response has been consumed.
+func (l *limiter) acquire(ctx context.Context, op Operation) (func(), error) {
+	start := time.Now()
+	defer func() { waitRecorderFrom(ctx).add(time.Since(start)) }()
+
+	bucket := l.global
+	if override, ok := l.endpoints[op]; ok {
+		bucket = override
+	}
+	if bucket != nil {
+		if err := bucket.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  float64(limit.Burst),
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the
+// caller must wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	delay := b.reserve(time.Now())
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		b.cancel()
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
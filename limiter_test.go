@@ -0,0 +1,66 @@
+package weheat_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+func TestRateLimitRecordsWait(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}), weheat.WithRateLimit(weheat.RateLimit{Rate: 10, Burst: 1}))
+
+	var rec weheat.WaitRecorder
+	ctx := weheat.WithWaitRecorder(testContext(t), &rec)
+	start := time.Now()
+	for range 3 {
+		if _, err := client.GetHeatPump(ctx, "hp", weheat.RequestOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// One token up front, then one every 100ms.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("3 requests took %v, want the limiter to pace them", elapsed)
+	}
+	if rec.Total() < 150*time.Millisecond {
+		t.Fatalf("recorded wait = %v", rec.Total())
+	}
+}
+
+func TestMaxConcurrencyCapsInFlightRequests(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}), weheat.WithMaxConcurrency(2))
+
+	ctx := testContext(t)
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetHeatPump(ctx, "hp", weheat.RequestOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("peak in-flight requests = %d, want 2", got)
+	}
+}
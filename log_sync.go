@@ -0,0 +1,298 @@
+package weheat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSyncChunk     = 6 * time.Hour
+	defaultSyncGapFactor = 2.5
+	defaultSyncSettle    = 15 * time.Minute
+)
+
+// CheckpointStore records how far each heat pump's raw logs have been synced.
+type CheckpointStore interface {
+	// Load returns the high-water mark for the heat pump, or false if none is stored.
+	Load(ctx context.Context, heatPumpID string) (time.Time, bool, error)
+	Save(ctx context.Context, heatPumpID string, mark time.Time) error
+}
+
+// LogSyncer incrementally copies a heat pump's raw logs into Sink, keeping a
+// high-water mark per heat pump so each run only fetches new data.
+type LogSyncer struct {
+	Client      *Client
+	Checkpoints CheckpointStore
+	// Sink receives each batch of new logs in time order. The checkpoint only
+	// advances once Sink has returned successfully.
+	Sink func(ctx context.Context, heatPumpID string, logs []RawHeatPumpLog) error
+	// Chunk is the time span requested at once; defaults to 6 hours.
+	Chunk time.Duration
+	// GapFactor flags a gap when consecutive samples are further apart than
+	// this multiple of the sample Interval; defaults to 2.5.
+	GapFactor float64
+	// Settle is how old an empty chunk must be before the checkpoint moves
+	// past it, giving late data time to arrive; defaults to 15 minutes.
+	Settle time.Duration
+	// RequestOptions are sent with every request.
+	RequestOptions RequestOptions
+}
+
+// LogGap is a stretch of missing samples that remained after re-requesting it.
+type LogGap struct {
+	Start time.Time
+	End   time.Time
+}
+
+// SyncResult summarises a Sync run.
+type SyncResult struct {
+	From    time.Time
+	To      time.Time
+	Fetched int
+	Gaps    []LogGap
+}
+
+// Sync fetches every raw log newer than the heat pump's checkpoint, or since
+// CommissionedAt on the first run, in resumable chunks up to now.
+func (s *LogSyncer) Sync(ctx context.Context, heatPumpID string) (*SyncResult, error) {
+	if s.Client == nil {
+		return nil, ErrClientMissing
+	}
+	if s.Checkpoints == nil {
+		return nil, errors.New("weheat: checkpoint store required")
+	}
+	if s.Sink == nil {
+		return nil, errors.New("weheat: sync sink required")
+	}
+
+	mark, ok, err := s.Checkpoints.Load(ctx, heatPumpID)
+	if err != nil {
+		return nil, err
+	}
+	// The checkpoint stands in for the previous run's last sample, so a gap
+	// that straddles two runs is still found.
+	var last *RawHeatPumpLog
+	if ok {
+		last = &RawHeatPumpLog{HeatPumpID: heatPumpID, Timestamp: mark}
+	} else {
+		pump, err := s.Client.GetHeatPump(ctx, heatPumpID, s.RequestOptions)
+		if err != nil {
+			return nil, err
+		}
+		if pump.CommissionedAt == nil {
+			return nil, errors.New("weheat: heat pump has no checkpoint or commission date")
+		}
+		mark = pump.CommissionedAt.Add(-time.Nanosecond)
+	}
+
+	chunk := s.Chunk
+	if chunk <= 0 {
+		chunk = defaultSyncChunk
+	}
+	settle := s.Settle
+	if settle <= 0 {
+		settle = defaultSyncSettle
+	}
+
+	now := time.Now()
+	result := &SyncResult{From: mark, To: now}
+	for from := mark; from.Before(now); {
+		to := from.Add(chunk)
+		if to.After(now) {
+			to = now
+		}
+
+		logs, gaps, err := s.fetchChunk(ctx, heatPumpID, from, to, last)
+		if err != nil {
+			return result, err
+		}
+		result.Gaps = append(result.Gaps, gaps...)
+
+		next := from
+		if len(logs) > 0 {
+			if err := s.Sink(ctx, heatPumpID, logs); err != nil {
+				return result, err
+			}
+			result.Fetched += len(logs)
+			last = &logs[len(logs)-1]
+			next = last.Timestamp
+		}
+		if now.Sub(to) >= settle && to.After(next) {
+			next = to
+		}
+		if next.After(from) {
+			if err := s.Checkpoints.Save(ctx, heatPumpID, next); err != nil {
+				return result, err
+			}
+		}
+		from = to
+	}
+	return result, nil
+}
+
+// fetchChunk returns the logs strictly after from and up to to, re-requesting
+// any gap once. prev is the last sample of the previous chunk, if any, so
+// gaps spanning a chunk boundary are caught too.
+func (s *LogSyncer) fetchChunk(ctx context.Context, heatPumpID string, from time.Time, to time.Time, prev *RawHeatPumpLog) ([]RawHeatPumpLog, []LogGap, error) {
+	logs, err := s.fetch(ctx, heatPumpID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gaps := s.findGaps(prev, logs)
+	if len(gaps) == 0 {
+		return logs, nil, nil
+	}
+	for _, gap := range gaps {
+		refill, err := s.fetch(ctx, heatPumpID, gap.Start, gap.End)
+		if err != nil {
+			return nil, nil, err
+		}
+		logs = append(logs, refill...)
+	}
+	logs = sortUniqueLogs(logs, from)
+	return logs, s.findGaps(prev, logs), nil
+}
+
+func (s *LogSyncer) fetch(ctx context.Context, heatPumpID string, from time.Time, to time.Time) ([]RawHeatPumpLog, error) {
+	query := LogQuery{StartTime: timePtr(from), EndTime: timePtr(to), RequestOptions: s.RequestOptions}
+	logs, err := s.Client.GetRawLogs(ctx, heatPumpID, query)
+	if err != nil {
+		return nil, err
+	}
+	return sortUniqueLogs(logs, from), nil
+}
+
+func (s *LogSyncer) findGaps(prev *RawHeatPumpLog, logs []RawHeatPumpLog) []LogGap {
+	factor := s.GapFactor
+	if factor <= 0 {
+		factor = defaultSyncGapFactor
+	}
+	series := logs
+	if prev != nil {
+		series = append([]RawHeatPumpLog{*prev}, logs...)
+	}
+	var gaps []LogGap
+	for i := 1; i < len(series); i++ {
+		prev, next := series[i-1], series[i]
+		interval := prev.Interval
+		if interval <= 0 {
+			interval = next.Interval
+		}
+		if interval <= 0 {
+			continue
+		}
+		expected := time.Duration(float64(interval) * factor * float64(time.Second))
+		if next.Timestamp.Sub(prev.Timestamp) > expected {
+			gaps = append(gaps, LogGap{Start: prev.Timestamp, End: next.Timestamp})
+		}
+	}
+	return gaps
+}
+
+// sortUniqueLogs orders logs by timestamp and drops duplicates and anything
+// at or before after.
+func sortUniqueLogs(logs []RawHeatPumpLog, after time.Time) []RawHeatPumpLog {
+	slices.SortStableFunc(logs, func(a, b RawHeatPumpLog) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	out := logs[:0]
+	for _, log := range logs {
+		if !log.Timestamp.After(after) {
+			continue
+		}
+		if len(out) > 0 && log.Timestamp.Equal(out[len(out)-1].Timestamp) {
+			continue
+		}
+		out = append(out, log)
+	}
+	return out
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory.
+type MemoryCheckpointStore struct {
+	mu    sync.Mutex
+	marks map[string]time.Time
+}
+
+// NewMemoryCheckpointStore returns an empty in-memory checkpoint store.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{marks: map[string]time.Time{}}
+}
+
+// Load returns the stored checkpoint.
+func (s *MemoryCheckpointStore) Load(_ context.Context, heatPumpID string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mark, ok := s.marks[heatPumpID]
+	return mark, ok, nil
+}
+
+// Save records a checkpoint.
+func (s *MemoryCheckpointStore) Save(_ context.Context, heatPumpID string, mark time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marks[heatPumpID] = mark
+	return nil
+}
+
+// FileCheckpointStore keeps all checkpoints in one JSON file, replacing it
+// atomically on every save.
+type FileCheckpointStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileCheckpointStore returns a store backed by the file at path.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// Load returns the stored checkpoint.
+func (s *FileCheckpointStore) Load(_ context.Context, heatPumpID string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	marks, err := s.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	mark, ok := marks[heatPumpID]
+	return mark, ok, nil
+}
+
+// Save records a checkpoint.
+func (s *FileCheckpointStore) Save(_ context.Context, heatPumpID string, mark time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	marks, err := s.read()
+	if err != nil {
+		return err
+	}
+	marks[heatPumpID] = mark
+	data, err := json.Marshal(marks)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0o644)
+}
+
+func (s *FileCheckpointStore) read() (map[string]time.Time, error) {
+	marks := map[string]time.Time{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return marks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &marks); err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
@@ -0,0 +1,104 @@
+package weheat_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+func TestLogSyncerResumesAndReportsGaps(t *testing.T) {
+	commissioned := time.Now().UTC().Truncate(time.Minute).Add(-3 * time.Hour)
+	outage := weheattest.Window{Start: commissioned.Add(time.Hour), End: commissioned.Add(90 * time.Minute)}
+	srv := weheattest.NewServer(weheattest.ServerConfig{Pumps: []*weheattest.SimulatedPump{{
+		ID:             "hp",
+		State:          weheat.DeviceStateActive,
+		CommissionedAt: commissioned,
+		Outages:        []weheattest.Window{outage},
+	}}})
+	defer srv.Close()
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[time.Time]bool{}
+	var last time.Time
+	syncer := &weheat.LogSyncer{
+		Client:      client,
+		Checkpoints: weheat.NewMemoryCheckpointStore(),
+		Chunk:       time.Hour,
+		Sink: func(_ context.Context, id string, logs []weheat.RawHeatPumpLog) error {
+			for _, log := range logs {
+				if seen[log.Timestamp] || !log.Timestamp.After(last) {
+					t.Errorf("log at %v delivered out of order or twice", log.Timestamp)
+				}
+				seen[log.Timestamp] = true
+				last = log.Timestamp
+			}
+			return nil
+		},
+	}
+	ctx := testContext(t)
+
+	first, err := syncer.Sync(ctx, "hp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three hours of 30s samples less the half-hour outage.
+	if want := 150 * 2; first.Fetched < want || first.Fetched > want+2 {
+		t.Errorf("first sync fetched %d logs, want about %d", first.Fetched, want)
+	}
+	if len(first.Gaps) != 1 || !first.Gaps[0].Start.Before(outage.Start) || first.Gaps[0].End.Before(outage.End) {
+		t.Errorf("gaps = %+v, want one covering %v–%v", first.Gaps, outage.Start, outage.End)
+	}
+
+	second, err := syncer.Sync(ctx, "hp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Fetched > 1 || !second.From.Equal(last) {
+		t.Errorf("second sync from %v fetched %d logs; want to resume at %v with at most one new log", second.From, second.Fetched, last)
+	}
+	if len(seen) != first.Fetched+second.Fetched {
+		t.Errorf("sink saw %d logs, syncs reported %d", len(seen), first.Fetched+second.Fetched)
+	}
+}
+
+func TestLogSyncerFindsGapsAcrossRuns(t *testing.T) {
+	commissioned := time.Now().UTC().Truncate(time.Minute).Add(-2 * time.Hour)
+	outage := weheattest.Window{Start: commissioned.Add(time.Hour), End: commissioned.Add(80 * time.Minute)}
+	srv := weheattest.NewServer(weheattest.ServerConfig{Pumps: []*weheattest.SimulatedPump{{
+		ID:             "hp",
+		State:          weheat.DeviceStateActive,
+		CommissionedAt: commissioned,
+		Outages:        []weheattest.Window{outage},
+	}}})
+	defer srv.Close()
+	client, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t)
+
+	// A previous run stopped at the last sample before the outage.
+	checkpoints := weheat.NewMemoryCheckpointStore()
+	lastSample := outage.Start.Add(-weheattest.DefaultLogInterval)
+	if err := checkpoints.Save(ctx, "hp", lastSample); err != nil {
+		t.Fatal(err)
+	}
+	syncer := &weheat.LogSyncer{
+		Client:      client,
+		Checkpoints: checkpoints,
+		Sink:        func(context.Context, string, []weheat.RawHeatPumpLog) error { return nil },
+	}
+	result, err := syncer.Sync(ctx, "hp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Gaps) != 1 || !result.Gaps[0].Start.Equal(lastSample) || !result.Gaps[0].End.Equal(outage.End) {
+		t.Fatalf("gaps = %+v, want %v–%v", result.Gaps, lastSample, outage.End)
+	}
+}
@@ -0,0 +1,241 @@
+package weheat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Redacted replaces values hidden from logs.
+const Redacted = "[REDACTED]"
+
+// maxLoggedBody is the longest body excerpt written to the log.
+const maxLoggedBody = 512
+
+// Redactor decides what is logged for a value. key is a header name, query
+// parameter or JSON field name; the returned string is logged in place of
+// value.
+type Redactor func(key, value string) string
+
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"client_secret": true,
+	"password":      true,
+	"code":          true,
+	"email":         true,
+	"firstname":     true,
+	"lastname":      true,
+}
+
+// DefaultRedactor hides credentials and personal data such as
+// ReadUserMe.Email. The scheme of an Authorization header is kept.
+func DefaultRedactor(key, value string) string {
+	if !sensitiveKeys[strings.ToLower(key)] || value == "" {
+		return value
+	}
+	if strings.EqualFold(key, "Authorization") {
+		if scheme, _, ok := strings.Cut(value, " "); ok {
+			return scheme + " " + Redacted
+		}
+	}
+	return Redacted
+}
+
+// requestLogger writes request and response records to a slog.Logger. Request
+// records are logged at debug level, failed attempts at warn and undecodable
+// responses at error.
+type requestLogger struct {
+	logger *slog.Logger
+	redact Redactor
+}
+
+func (l *requestLogger) enabled(ctx context.Context, level slog.Level) bool {
+	return l != nil && l.logger != nil && l.logger.Enabled(ctx, level)
+}
+
+func (l *requestLogger) request(ctx context.Context, req *http.Request, attempt int) {
+	if !l.enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Int("attempt", attempt),
+	}
+	if query := l.query(req.URL.Query()); query != "" {
+		attrs = append(attrs, slog.String("query", query))
+	}
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		attrs = append(attrs, slog.String("authorization", l.redact("Authorization", auth)))
+	}
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "weheat: request", l.withCall(ctx, attrs)...)
+}
+
+func (l *requestLogger) response(ctx context.Context, req *http.Request, resp *http.Response, body []byte, elapsed time.Duration, err error) {
+	level := slog.LevelDebug
+	if err != nil || resp.StatusCode >= 400 {
+		level = slog.LevelWarn
+	}
+	if !l.enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", elapsed),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", l.error(err)))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.StatusCode >= 400 && len(body) > 0 {
+			attrs = append(attrs, slog.String("body", l.excerpt(body)))
+		}
+	}
+	l.logger.LogAttrs(ctx, level, "weheat: response", l.withCall(ctx, attrs)...)
+}
+
+func (l *requestLogger) decodeFailure(ctx context.Context, r request, body []byte, err error) {
+	if !l.enabled(ctx, slog.LevelError) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("operation", string(r.operation))}
+	if r.heatPumpID != "" {
+		attrs = append(attrs, slog.String("heat_pump_id", r.heatPumpID))
+	}
+	attrs = append(attrs,
+		slog.String("method", r.method),
+		slog.String("path", r.path),
+		slog.String("error", err.Error()),
+		slog.String("body", l.excerpt(body)),
+	)
+	l.logger.LogAttrs(ctx, slog.LevelError, "weheat: decode failed", attrs...)
+}
+
+func (l *requestLogger) withCall(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	info, ok := CallInfoFromContext(ctx)
+	if !ok {
+		return attrs
+	}
+	call := []slog.Attr{slog.String("operation", string(info.Operation))}
+	if info.HeatPumpID != "" {
+		call = append(call, slog.String("heat_pump_id", info.HeatPumpID))
+	}
+	return append(call, attrs...)
+}
+
+func (l *requestLogger) query(values url.Values) string {
+	if len(values) == 0 {
+		return ""
+	}
+	redacted := make(url.Values, len(values))
+	for key, list := range values {
+		for _, value := range list {
+			// Free-text parameters such as Search may hold an e-mail address.
+			redacted.Add(key, l.redactText(l.redact(key, value)))
+		}
+	}
+	// Decoded so redaction markers stay readable.
+	query, err := url.QueryUnescape(redacted.Encode())
+	if err != nil {
+		return redacted.Encode()
+	}
+	return query
+}
+
+// error redacts the query of the URL a transport error quotes.
+func (l *requestLogger) error(err error) string {
+	msg := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil && u.RawQuery != "" {
+			u.RawQuery = l.query(u.Query())
+			msg = strings.ReplaceAll(msg, urlErr.URL, u.String())
+		}
+	}
+	return msg
+}
+
+// excerpt redacts a response body and truncates it for logging.
+func (l *requestLogger) excerpt(body []byte) string {
+	var text string
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&value) == nil {
+		data, err := json.Marshal(l.redactJSON("", value))
+		if err == nil {
+			text = string(data)
+		}
+	}
+	if text == "" {
+		text = l.redactText(string(body))
+	}
+	if len(text) > maxLoggedBody {
+		text = strings.ToValidUTF8(text[:maxLoggedBody], "") + "…"
+	}
+	return text
+}
+
+func (l *requestLogger) redactJSON(key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, field := range v {
+			v[k] = l.redactJSON(k, field)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = l.redactJSON(key, v[i])
+		}
+		return v
+	case string:
+		return l.redact(key, v)
+	case json.Number:
+		if redacted := l.redact(key, v.String()); redacted != v.String() {
+			return redacted
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+var (
+	jsonStringField = regexp.MustCompile(`"([^"\\]+)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+	emailAddress    = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// redactText handles bodies that are not valid JSON, such as truncated ones,
+// by redacting every "key": "value" pair and e-mail address it can find.
+func (l *requestLogger) redactText(body string) string {
+	body = emailAddress.ReplaceAllStringFunc(body, func(addr string) string {
+		return l.redact("email", addr)
+	})
+	return jsonStringField.ReplaceAllStringFunc(body, func(field string) string {
+		match := jsonStringField.FindStringSubmatch(field)
+		redacted := l.redact(match[1], match[2])
+		if redacted == match[2] {
+			return field
+		}
+		quoted, _ := json.Marshal(redacted)
+		return `"` + match[1] + `":` + string(quoted)
+	})
+}
+
+func (c *Client) ensureLogger() *requestLogger {
+	if c.log == nil {
+		c.log = &requestLogger{redact: DefaultRedactor}
+	}
+	return c.log
+}
@@ -0,0 +1,117 @@
+package weheat_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+// secrets appear in the fake responses below and must never reach the log.
+var secrets = []string{"jane@example.com", "Jane", "Doppelganger", "sekret-access", "sekret-refresh", "sekret-code", "token-value"}
+
+func newLoggedClient(t *testing.T, handler http.Handler, opts ...weheat.ClientOption) (*weheat.Client, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	opts = append([]weheat.ClientOption{
+		weheat.WithLogger(logger),
+		weheat.WithTokenSource(weheat.StaticToken("token-value")),
+	}, opts...)
+	return newTestClient(t, handler, opts...), &logs
+}
+
+func assertRedacted(t *testing.T, logs *bytes.Buffer) {
+	t.Helper()
+	for _, secret := range secrets {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("log contains %q:\n%s", secret, logs.String())
+		}
+	}
+	if !strings.Contains(logs.String(), weheat.Redacted) {
+		t.Errorf("log has no redaction markers:\n%s", logs.String())
+	}
+}
+
+func TestLoggingRedactsAuthorization(t *testing.T) {
+	client, logs := newLoggedClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}))
+	if _, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assertRedacted(t, logs)
+	if !strings.Contains(logs.String(), `authorization="Bearer [REDACTED]"`) {
+		t.Errorf("Authorization scheme not kept:\n%s", logs.String())
+	}
+}
+
+func TestLoggingRedactsErrorBodies(t *testing.T) {
+	bodies := map[string]string{
+		"json": `{"email":"jane@example.com","firstName":"Jane","lastName":"Doppelganger",` +
+			`"tokens":[{"access_token":"sekret-access","refresh_token":"sekret-refresh"}],"code":"sekret-code"}`,
+		// Cut short, so only the text fallback can redact it.
+		"truncated": `{"detail":"no access for jane@example.com","firstName":"Jane","lastName":"Doppelganger","code":"sekret-code","refresh_token":"sekret-ref`,
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			client, logs := newLoggedClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, body)
+			}))
+			if _, err := client.GetUserMe(testContext(t), weheat.RequestOptions{}); err == nil {
+				t.Fatal("want error")
+			}
+			if !strings.Contains(logs.String(), "status=403") {
+				t.Fatalf("failed response not logged:\n%s", logs.String())
+			}
+			assertRedacted(t, logs)
+		})
+	}
+}
+
+func TestLoggingRedactsUndecodableBody(t *testing.T) {
+	client, logs := newLoggedClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"email":"jane@example.com","firstName":"Jane","lastName":"Doppelganger","id":`)
+	}))
+	if _, err := client.GetUserMe(testContext(t), weheat.RequestOptions{}); err == nil {
+		t.Fatal("want decode error")
+	}
+	if !strings.Contains(logs.String(), "decode failed") {
+		t.Fatalf("decode failure not logged:\n%s", logs.String())
+	}
+	assertRedacted(t, logs)
+}
+
+func TestLoggingRedactsURLs(t *testing.T) {
+	failing := func(next weheat.Doer) weheat.Doer {
+		return weheat.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, &url.Error{Op: req.Method, URL: req.URL.String(), Err: errors.New("connection reset")}
+		})
+	}
+	client, logs := newLoggedClient(t, http.NotFoundHandler(), weheat.WithMiddleware(failing))
+	if _, err := client.ListHeatPumps(testContext(t), weheat.ListHeatPumpsParams{Search: "jane@example.com"}); err == nil {
+		t.Fatal("want error")
+	}
+	if !strings.Contains(logs.String(), "connection reset") {
+		t.Fatalf("transport error not logged:\n%s", logs.String())
+	}
+	assertRedacted(t, logs)
+
+	// Sensitive query parameters are redacted by name.
+	if got := weheat.DefaultRedactor("code", "sekret-code"); got != weheat.Redacted {
+		t.Errorf("DefaultRedactor(code) = %q", got)
+	}
+	if got := weheat.DefaultRedactor("access_token", "sekret-access"); got != weheat.Redacted {
+		t.Errorf("DefaultRedactor(access_token) = %q", got)
+	}
+	if got := weheat.DefaultRedactor("interval", "Hour"); got != "Hour" {
+		t.Errorf("DefaultRedactor(interval) = %q", got)
+	}
+}
@@ -0,0 +1,220 @@
+package weheat
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"time"
+)
+
+const defaultRangeConcurrency = 4
+
+// RangeOptions controls how a long log query is split into requests.
+type RangeOptions struct {
+	// Window is the time span of each request. Defaults depend on the
+	// query interval.
+	Window time.Duration
+	// Concurrency bounds the number of windows fetched in parallel; defaults to 4.
+	Concurrency int
+}
+
+// FetchRawLogsRange fetches raw logs between query.StartTime and
+// query.EndTime (default now) in windows, returning them de-duplicated and
+// sorted by timestamp.
+func (c *Client) FetchRawLogsRange(ctx context.Context, heatPumpID string, query LogQuery, opts RangeOptions) ([]RawHeatPumpLog, error) {
+	return collectRange(c.RawLogsRange(ctx, heatPumpID, query, opts))
+}
+
+// FetchLogsRange fetches aggregated log views between query.StartTime and
+// query.EndTime (default now) in windows, returning them de-duplicated and
+// sorted by time bucket.
+func (c *Client) FetchLogsRange(ctx context.Context, heatPumpID string, query LogQuery, opts RangeOptions) ([]HeatPumpLogView, error) {
+	return collectRange(c.LogsRange(ctx, heatPumpID, query, opts))
+}
+
+// RawLogsRange is the streaming form of FetchRawLogsRange. Entries are
+// yielded in time order as soon as their window has arrived.
+func (c *Client) RawLogsRange(ctx context.Context, heatPumpID string, query LogQuery, opts RangeOptions) iter.Seq2[RawHeatPumpLog, error] {
+	fetch := func(ctx context.Context, q LogQuery) ([]RawHeatPumpLog, error) {
+		return c.GetRawLogs(ctx, heatPumpID, q)
+	}
+	stamp := func(log RawHeatPumpLog) (time.Time, bool) { return log.Timestamp, true }
+	return fetchRange(ctx, query, opts, fetch, stamp)
+}
+
+// LogsRange is the streaming form of FetchLogsRange.
+func (c *Client) LogsRange(ctx context.Context, heatPumpID string, query LogQuery, opts RangeOptions) iter.Seq2[HeatPumpLogView, error] {
+	fetch := func(ctx context.Context, q LogQuery) ([]HeatPumpLogView, error) {
+		return c.GetLogs(ctx, heatPumpID, q)
+	}
+	stamp := func(view HeatPumpLogView) (time.Time, bool) {
+		if view.TimeBucket == nil {
+			return time.Time{}, false
+		}
+		return *view.TimeBucket, true
+	}
+	return fetchRange(ctx, query, opts, fetch, stamp)
+}
+
+type rangeResult[T any] struct {
+	items []T
+	err   error
+}
+
+// fetchRange drops entries whose timestamp repeats or goes back in time;
+// entries stamp reports no timestamp for are always yielded.
+func fetchRange[T any](ctx context.Context, query LogQuery, opts RangeOptions, fetch func(context.Context, LogQuery) ([]T, error), stamp func(T) (time.Time, bool)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		windows, err := splitLogQuery(query, opts.Window, time.Now())
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		concurrency := opts.Concurrency
+		if concurrency <= 0 {
+			concurrency = defaultRangeConcurrency
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make([]chan rangeResult[T], len(windows))
+		start := func(i int) {
+			results[i] = make(chan rangeResult[T], 1)
+			go func() {
+				items, err := fetch(ctx, windows[i])
+				results[i] <- rangeResult[T]{items: items, err: err}
+			}()
+		}
+		for i := 0; i < concurrency && i < len(windows); i++ {
+			start(i)
+		}
+
+		var last time.Time
+		emitted := false
+		for i := range windows {
+			res := <-results[i]
+			if next := i + concurrency; next < len(windows) {
+				start(next)
+			}
+			if res.err != nil {
+				yield(zero, res.err)
+				return
+			}
+			slices.SortStableFunc(res.items, func(a, b T) int {
+				ta, _ := stamp(a)
+				tb, _ := stamp(b)
+				return ta.Compare(tb)
+			})
+			for _, item := range res.items {
+				if ts, ok := stamp(item); ok {
+					if emitted && !ts.After(last) {
+						continue
+					}
+					last, emitted = ts, true
+				}
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func collectRange[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var out []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// splitLogQuery cuts the query's time range into consecutive windows whose
+// inner boundaries fall on bucket edges, so aggregated buckets are never
+// split across requests.
+func splitLogQuery(query LogQuery, window time.Duration, now time.Time) ([]LogQuery, error) {
+	if query.StartTime == nil {
+		return nil, errors.New("weheat: range start time required")
+	}
+	start := *query.StartTime
+	end := now
+	if query.EndTime != nil {
+		end = *query.EndTime
+	}
+	if !end.After(start) {
+		return nil, errors.New("weheat: range end must be after start")
+	}
+	if window <= 0 {
+		window = defaultRangeWindow(query.Interval)
+	}
+	bucket := logIntervalDuration(query.Interval)
+	if bucket > 0 && window < bucket {
+		window = bucket
+	}
+
+	var out []LogQuery
+	for from := start; from.Before(end); {
+		to := from.Add(window)
+		if bucket > 0 {
+			to = to.Truncate(bucket)
+			if !to.After(from) {
+				to = from.Add(bucket)
+			}
+		}
+		if to.After(end) {
+			to = end
+		}
+		q := query
+		q.StartTime = timePtr(from)
+		q.EndTime = timePtr(to)
+		out = append(out, q)
+		from = to
+	}
+	return out, nil
+}
+
+// defaultRangeWindow sizes requests so each returns roughly a day of
+// minute-level samples or its equivalent for coarser intervals.
+func defaultRangeWindow(interval LogInterval) time.Duration {
+	const day = 24 * time.Hour
+	switch interval {
+	case LogIntervalFiveMinute:
+		return 3 * day
+	case LogIntervalFifteenMinute:
+		return 7 * day
+	case LogIntervalHour:
+		return 31 * day
+	case LogIntervalDay:
+		return 366 * day
+	case LogIntervalWeek, LogIntervalMonth, LogIntervalYear:
+		return 5 * 366 * day
+	default:
+		return day
+	}
+}
+
+// logIntervalDuration returns the fixed bucket length of an interval, or 0
+// for calendar-based intervals.
+func logIntervalDuration(interval LogInterval) time.Duration {
+	switch interval {
+	case LogIntervalMinute:
+		return time.Minute
+	case LogIntervalFiveMinute:
+		return 5 * time.Minute
+	case LogIntervalFifteenMinute:
+		return 15 * time.Minute
+	case LogIntervalHour:
+		return time.Hour
+	default:
+		return 0
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
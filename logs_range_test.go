@@ -0,0 +1,79 @@
+package weheat_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+func TestFetchRawLogsRangeSplitsWindows(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	srv := weheattest.NewServer(weheattest.ServerConfig{Clock: func() time.Time { return now }})
+	defer srv.Close()
+	var counts sync.Map
+	client, err := srv.NewClient(weheat.WithMiddleware(countRequests(&counts)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t)
+	id := defaultPumpID
+	start, end := now.Add(-3*time.Hour), now
+
+	whole, err := client.GetRawLogs(ctx, id, weheat.LogQuery{StartTime: &start, EndTime: &end})
+	if err != nil {
+		t.Fatal(err)
+	}
+	split, err := client.FetchRawLogsRange(ctx, id, weheat.LogQuery{StartTime: &start, EndTime: &end}, weheat.RangeOptions{Window: time.Hour, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := requestCount(&counts, weheat.OperationGetRawLogs); got != 4 {
+		t.Fatalf("%d raw log requests, want 1 whole and 3 windows", got)
+	}
+	if len(split) != len(whole) {
+		t.Fatalf("split returned %d logs, whole range %d", len(split), len(whole))
+	}
+	for i := 1; i < len(split); i++ {
+		if !split[i].Timestamp.After(split[i-1].Timestamp) {
+			t.Fatalf("logs not sorted and unique at %d: %v then %v", i, split[i-1].Timestamp, split[i].Timestamp)
+		}
+	}
+}
+
+func TestFetchLogsRangeKeepsViewsWithoutBuckets(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	bucket := func(hours int) *time.Time {
+		v := start.Add(time.Duration(hours) * time.Hour)
+		return &v
+	}
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		views := []weheat.HeatPumpLogView{{}, {}, {TimeBucket: bucket(0)}}
+		if r.URL.Query().Get("startTime") != start.Format("2006-01-02T15:04:05.000000-0700") {
+			// The second window repeats the first one's last bucket.
+			views = []weheat.HeatPumpLogView{{}, {TimeBucket: bucket(0)}, {TimeBucket: bucket(1)}}
+		}
+		json.NewEncoder(w).Encode(views)
+	}))
+
+	views, err := client.FetchLogsRange(testContext(t), "hp", weheat.LogQuery{StartTime: &start, EndTime: &end, Interval: weheat.LogIntervalHour}, weheat.RangeOptions{Window: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unbucketed, bucketed int
+	for _, view := range views {
+		if view.TimeBucket == nil {
+			unbucketed++
+		} else {
+			bucketed++
+		}
+	}
+	if unbucketed != 3 || bucketed != 2 {
+		t.Fatalf("got %d views without a bucket and %d with; want 3 and 2", unbucketed, bucketed)
+	}
+}
@@ -0,0 +1,111 @@
+package weheat
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Doer sends an HTTP request. *http.Client satisfies it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the Doer that sends each HTTP attempt.
+type Middleware func(next Doer) Doer
+
+// CallInfo describes the logical API call an HTTP request belongs to.
+type CallInfo struct {
+	Operation  Operation
+	HeatPumpID string
+	// Attempt is 1 for the first try and increases with every retry.
+	Attempt int
+}
+
+type callInfoKey struct{}
+
+// CallInfoFromContext returns the call info attached to a request's context
+// by the client. Middleware reads it from req.Context().
+func CallInfoFromContext(ctx context.Context) (CallInfo, bool) {
+	info, ok := ctx.Value(callInfoKey{}).(CallInfo)
+	return info, ok
+}
+
+func withCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// buildDoer wraps the HTTP client in the configured middleware, the first
+// one registered being the outermost.
+func (c *Client) buildDoer() Doer {
+	var doer Doer = c.httpClient
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		doer = c.middleware[i](doer)
+	}
+	return doer
+}
+
+// LoggingMiddleware logs one info record per HTTP attempt with the
+// operation, heat pump ID, attempt, method, path, status or error, and
+// duration. The query string and headers are left out, so nothing needs
+// redacting; use WithLogger for full request logging. A nil logger logs to
+// slog.Default().
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			info, _ := CallInfoFromContext(ctx)
+			start := time.Now()
+			resp, err := next.Do(req)
+			attrs := []slog.Attr{slog.String("operation", string(info.Operation))}
+			if info.HeatPumpID != "" {
+				attrs = append(attrs, slog.String("heat_pump_id", info.HeatPumpID))
+			}
+			attrs = append(attrs,
+				slog.Int("attempt", info.Attempt),
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+			)
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			} else {
+				attrs = append(attrs, slog.Int("status", resp.StatusCode))
+			}
+			attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+			logger.LogAttrs(ctx, slog.LevelInfo, "weheat: http attempt", attrs...)
+			return resp, err
+		})
+	}
+}
+
+// HeaderMiddleware sets the given headers on every request, replacing any
+// existing values. Authorization is left alone, as the client sets it from
+// its token source.
+func HeaderMiddleware(headers http.Header) Middleware {
+	headers = headers.Clone()
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for key, values := range headers {
+				if strings.EqualFold(key, "Authorization") {
+					continue
+				}
+				req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+			}
+			return next.Do(req)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package weheat_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+func TestLoggingMiddlewareLogsEachAttempt(t *testing.T) {
+	var logs bytes.Buffer
+	var calls atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}), fastRetries(2), weheat.WithMiddleware(weheat.LoggingMiddleware(slog.New(slog.NewJSONHandler(&logs, nil)))))
+
+	if _, err := client.GetRawLogs(testContext(t), "hp", weheat.LogQuery{Interval: weheat.LogIntervalHour}); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]any
+	dec := json.NewDecoder(&logs)
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("logged %d records, want one per attempt:\n%s", len(records), logs.String())
+	}
+	for i, want := range []float64{503, 200} {
+		record := records[i]
+		if record["msg"] != "weheat: http attempt" || record["level"] != "INFO" {
+			t.Errorf("record %d = %v", i, record)
+		}
+		if record["operation"] != string(weheat.OperationGetRawLogs) || record["heat_pump_id"] != "hp" || record["attempt"] != float64(i+1) {
+			t.Errorf("record %d call info = %v", i, record)
+		}
+		if record["method"] != http.MethodGet || record["path"] != "/api/v1/heat-pumps/hp/logs/raw" || record["status"] != want {
+			t.Errorf("record %d = %v, want status %v", i, record, want)
+		}
+		if _, ok := record["duration"]; !ok {
+			t.Errorf("record %d has no duration", i)
+		}
+	}
+	if bytes.Contains(logs.Bytes(), []byte("interval")) || bytes.Contains(logs.Bytes(), []byte("token")) {
+		t.Errorf("query or credentials logged:\n%s", logs.String())
+	}
+}
+
+func TestHeaderMiddlewareSetsHeadersButNotAuthorization(t *testing.T) {
+	var got http.Header
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}), weheat.WithMiddleware(weheat.HeaderMiddleware(http.Header{
+		"X-Request-Source": {"gateway-7"},
+		"Accept":           {"application/json"},
+		"Authorization":    {"Bearer stolen"},
+		"authorization":    {"Bearer stolen"},
+	})))
+
+	if _, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Get("X-Request-Source"); v != "gateway-7" {
+		t.Errorf("X-Request-Source = %q, want gateway-7", v)
+	}
+	if v := got.Values("Accept"); len(v) != 1 || v[0] != "application/json" {
+		t.Errorf("Accept = %q, want the middleware's value to replace the client's", v)
+	}
+	if v := got.Values("Authorization"); len(v) != 1 || v[0] != "Bearer token" {
+		t.Errorf("Authorization = %q, want the client's token", v)
+	}
+}
@@ -0,0 +1,108 @@
+package weheat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultIssuer is the production Weheat Keycloak realm.
+const DefaultIssuer = "https://auth.weheat.nl/auth/realms/Weheat"
+
+const oidcCacheTTL = time.Hour
+
+// OIDCConfiguration mirrors the OpenID provider metadata document.
+type OIDCConfiguration struct {
+	Issuer                      string   `json:"issuer"`
+	AuthorizationEndpoint       string   `json:"authorization_endpoint"`
+	TokenEndpoint               string   `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string   `json:"device_authorization_endpoint,omitempty"`
+	UserinfoEndpoint            string   `json:"userinfo_endpoint,omitempty"`
+	RevocationEndpoint          string   `json:"revocation_endpoint,omitempty"`
+	EndSessionEndpoint          string   `json:"end_session_endpoint,omitempty"`
+	IntrospectionEndpoint       string   `json:"introspection_endpoint,omitempty"`
+	JWKSURI                     string   `json:"jwks_uri,omitempty"`
+	ScopesSupported             []string `json:"scopes_supported,omitempty"`
+	GrantTypesSupported         []string `json:"grant_types_supported,omitempty"`
+}
+
+type cachedOIDCConfiguration struct {
+	config  *OIDCConfiguration
+	fetched time.Time
+}
+
+var oidcCache = struct {
+	mu      sync.Mutex
+	entries map[string]cachedOIDCConfiguration
+}{entries: map[string]cachedOIDCConfiguration{}}
+
+// OIDCDiscover fetches the issuer's .well-known/openid-configuration. Results
+// are cached per issuer for an hour. An *http.Client stored in ctx under
+// oauth2.HTTPClient is used when present.
+func OIDCDiscover(ctx context.Context, issuer string) (*OIDCConfiguration, error) {
+	issuer = strings.TrimRight(issuer, "/")
+	if issuer == "" {
+		return nil, errors.New("weheat: issuer required")
+	}
+
+	oidcCache.mu.Lock()
+	entry, ok := oidcCache.entries[issuer]
+	oidcCache.mu.Unlock()
+	if ok && time.Since(entry.fetched) < oidcCacheTTL {
+		clone := *entry.config
+		return &clone, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, body)
+	}
+
+	var config OIDCConfiguration
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, err
+	}
+	if config.Issuer != "" && strings.TrimRight(config.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("weheat: discovery issuer mismatch: got %q, want %q", config.Issuer, issuer)
+	}
+	if config.TokenEndpoint == "" {
+		return nil, errors.New("weheat: discovery document has no token endpoint")
+	}
+
+	oidcCache.mu.Lock()
+	oidcCache.entries[issuer] = cachedOIDCConfiguration{config: &config, fetched: time.Now()}
+	oidcCache.mu.Unlock()
+
+	clone := config
+	return &clone, nil
+}
+
+func oauthHTTPClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return &http.Client{Timeout: 15 * time.Second}
+}
@@ -0,0 +1,15 @@
+package weheat
+
+// Operation names a logical Weheat API call.
+type Operation string
+
+const (
+	OperationGetUserMe       Operation = "GetUserMe"
+	OperationListHeatPumps   Operation = "ListHeatPumps"
+	OperationGetHeatPump     Operation = "GetHeatPump"
+	OperationGetLatestLog    Operation = "GetLatestLog"
+	OperationGetRawLogs      Operation = "GetRawLogs"
+	OperationGetLogs         Operation = "GetLogs"
+	OperationGetEnergyLogs   Operation = "GetEnergyLogs"
+	OperationGetEnergyTotals Operation = "GetEnergyTotals"
+)
@@ -2,8 +2,13 @@ package weheat
 
 import (
 	"errors"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -58,3 +63,154 @@ func WithUserAgent(agent string) ClientOption {
 		return nil
 	}
 }
+
+// WithRetryPolicy retries idempotent requests that fail with a network error,
+// 429 or 5xx response using jittered exponential backoff.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		c.retryPolicy = &policy
+		return nil
+	}
+}
+
+// WithRateLimit applies a token-bucket limit shared by every request the
+// client sends, including retries.
+func WithRateLimit(limit RateLimit) ClientOption {
+	return func(c *Client) error {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+		c.ensureLimiter().global = newTokenBucket(limit)
+		return nil
+	}
+}
+
+// WithEndpointRateLimit gives an operation its own token bucket in place of
+// the limit set by WithRateLimit.
+func WithEndpointRateLimit(op Operation, limit RateLimit) ClientOption {
+	return func(c *Client) error {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+		c.ensureLimiter().endpoints[op] = newTokenBucket(limit)
+		return nil
+	}
+}
+
+// WithMaxConcurrency caps the number of requests in flight at once.
+func WithMaxConcurrency(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return errors.New("weheat: max concurrency must be at least 1")
+		}
+		c.ensureLimiter().slots = make(chan struct{}, n)
+		return nil
+	}
+}
+
+// WithMaxResponseSize caps the size of buffered response bodies. Streaming
+// calls such as StreamRawLogs are not limited.
+func WithMaxResponseSize(n int64) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return errors.New("weheat: max response size must be positive")
+		}
+		c.maxResponseSize = n
+		return nil
+	}
+}
+
+// WithCache caches GET responses for the operations in ttls, such as those
+// from DefaultCacheTTLs. Cache-Control and ETag headers are honoured: stale
+// entries with an ETag are revalidated with If-None-Match.
+func WithCache(cache Cache, ttls map[Operation]time.Duration) ClientOption {
+	return func(c *Client) error {
+		if cache == nil {
+			return errors.New("weheat: cache required")
+		}
+		copied := make(map[Operation]time.Duration, len(ttls))
+		for op, ttl := range ttls {
+			copied[op] = ttl
+		}
+		c.cache = &responseCache{store: cache, ttls: copied}
+		return nil
+	}
+}
+
+// WithRequestCoalescing makes concurrent identical GET requests share a
+// single round-trip. Requests are identical when their method, path, query
+// and RequestOptions match; every caller decodes the same response body.
+// The shared request is cancelled once every caller's context has ended.
+func WithRequestCoalescing() ClientOption {
+	return func(c *Client) error {
+		c.flights = &flightGroup{}
+		return nil
+	}
+}
+
+// WithMiddleware wraps every HTTP attempt in the given middleware. The first
+// middleware is the outermost; CallInfoFromContext identifies the call.
+func WithMiddleware(middleware ...Middleware) ClientOption {
+	return func(c *Client) error {
+		for _, mw := range middleware {
+			if mw == nil {
+				return errors.New("weheat: middleware required")
+			}
+		}
+		c.middleware = append(c.middleware, middleware...)
+		return nil
+	}
+}
+
+// WithTracerProvider records a client span for every API operation, covering
+// all of its retries and, for StreamRawLogs, reading the stream. Cache hits
+// and coalesced calls get a span of their own.
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(c *Client) error {
+		if provider == nil {
+			return errors.New("weheat: tracer provider required")
+		}
+		c.tracerProvider = provider
+		return nil
+	}
+}
+
+// WithMeterProvider records an operation duration histogram and an error
+// counter, both labelled with the operation name.
+func WithMeterProvider(provider metric.MeterProvider) ClientOption {
+	return func(c *Client) error {
+		if provider == nil {
+			return errors.New("weheat: meter provider required")
+		}
+		c.meterProvider = provider
+		return nil
+	}
+}
+
+// WithLogger logs every HTTP attempt to logger: requests and responses at
+// debug level, failed attempts at warn and undecodable responses at error,
+// with a truncated body excerpt. Values are passed through DefaultRedactor
+// unless WithLogRedactor is used.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) error {
+		if logger == nil {
+			return errors.New("weheat: logger required")
+		}
+		c.ensureLogger().logger = logger
+		return nil
+	}
+}
+
+// WithLogRedactor replaces DefaultRedactor for values written by WithLogger.
+func WithLogRedactor(redact Redactor) ClientOption {
+	return func(c *Client) error {
+		if redact == nil {
+			return errors.New("weheat: redactor required")
+		}
+		c.ensureLogger().redact = redact
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+package weheat
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/joshp123/weheat-golang"
+
+// telemetry holds the tracer and instruments used to record API calls. A nil
+// telemetry records nothing.
+type telemetry struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider) (*telemetry, error) {
+	if tp == nil && mp == nil {
+		return nil, nil
+	}
+	t := &telemetry{}
+	if tp != nil {
+		t.tracer = tp.Tracer(instrumentationName)
+	}
+	if mp != nil {
+		meter := mp.Meter(instrumentationName)
+		var err error
+		t.duration, err = meter.Float64Histogram("weheat.client.operation.duration",
+			metric.WithDescription("Duration of Weheat API operations, including retries."),
+			metric.WithUnit("s"))
+		if err != nil {
+			return nil, err
+		}
+		t.errors, err = meter.Int64Counter("weheat.client.operation.errors",
+			metric.WithDescription("Number of failed Weheat API operations."),
+			metric.WithUnit("{error}"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// outcome is how an operation ended. A cache hit or a coalesced caller makes
+// no attempts of its own.
+type outcome struct {
+	status    int
+	attempts  int
+	cacheHit  bool
+	coalesced bool
+	err       error
+}
+
+// start begins recording an operation. The returned function ends it with the
+// operation's outcome.
+func (t *telemetry) start(ctx context.Context, r request) (context.Context, func(outcome)) {
+	if t == nil {
+		return ctx, func(outcome) {}
+	}
+
+	var span trace.Span
+	if t.tracer != nil {
+		attrs := []attribute.KeyValue{
+			attribute.String("weheat.operation", string(r.operation)),
+			attribute.String("http.request.method", r.method),
+			attribute.String("url.path", r.path),
+		}
+		if r.heatPumpID != "" {
+			attrs = append(attrs, attribute.String("weheat.heat_pump.id", r.heatPumpID))
+		}
+		ctx, span = t.tracer.Start(ctx, string(r.operation),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...))
+	}
+	start := time.Now()
+
+	return ctx, func(o outcome) {
+		status, err := o.status, o.err
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			status = apiErr.StatusCode
+		}
+		result := []attribute.KeyValue{
+			attribute.Bool("weheat.cache_hit", o.cacheHit),
+			attribute.Bool("weheat.coalesced", o.coalesced),
+		}
+		if status != 0 {
+			result = append(result, attribute.Int("http.response.status_code", status))
+		}
+		if err != nil {
+			result = append(result, attribute.String("error.type", errorType(status, err)))
+		}
+
+		if span != nil {
+			span.SetAttributes(result...)
+			span.SetAttributes(attribute.Int("weheat.retry_count", max(o.attempts-1, 0)))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+		if t.duration != nil {
+			set := metric.WithAttributes(append([]attribute.KeyValue{
+				attribute.String("weheat.operation", string(r.operation)),
+				attribute.String("http.request.method", r.method),
+			}, result...)...)
+			t.duration.Record(ctx, time.Since(start).Seconds(), set)
+			if err != nil {
+				t.errors.Add(ctx, 1, set)
+			}
+		}
+	}
+}
+
+// errorType classifies a failure for the error.type attribute.
+func errorType(status int, err error) string {
+	switch {
+	case status >= 400:
+		return strconv.Itoa(status)
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrResponseTooLarge):
+		return "response_too_large"
+	default:
+		return "_OTHER"
+	}
+}
@@ -0,0 +1,242 @@
+package weheat_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+type otelRecorder struct {
+	spans  *tracetest.SpanRecorder
+	reader *sdkmetric.ManualReader
+}
+
+func newOtelRecorder() (*otelRecorder, []weheat.ClientOption) {
+	rec := &otelRecorder{spans: tracetest.NewSpanRecorder(), reader: sdkmetric.NewManualReader()}
+	return rec, []weheat.ClientOption{
+		weheat.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec.spans))),
+		weheat.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(rec.reader))),
+	}
+}
+
+func (r *otelRecorder) onlySpan(t *testing.T) sdktrace.ReadOnlySpan {
+	t.Helper()
+	spans := r.spans.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	return spans[0]
+}
+
+// sums returns the histogram sample count and error counter total.
+func (r *otelRecorder) sums(t *testing.T) (durations uint64, errors int64) {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := r.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				if m.Name == "weheat.client.operation.duration" {
+					for _, point := range data.DataPoints {
+						durations += point.Count
+					}
+				}
+			case metricdata.Sum[int64]:
+				if m.Name == "weheat.client.operation.errors" {
+					for _, point := range data.DataPoints {
+						errors += point.Value
+					}
+				}
+			}
+		}
+	}
+	return durations, errors
+}
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTelemetryRecordsRetriedOperation(t *testing.T) {
+	var calls atomic.Int32
+	rec, opts := newOtelRecorder()
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}), append(opts, weheat.WithRetryPolicy(weheat.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))...)
+
+	if _, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	span := rec.onlySpan(t)
+	attrs := spanAttrs(span)
+	if span.Name() != "GetHeatPump" || span.Status().Code == codes.Error {
+		t.Fatalf("span %q status %v", span.Name(), span.Status())
+	}
+	if got := attrs["http.response.status_code"].AsInt64(); got != 200 {
+		t.Errorf("status_code = %d, want 200", got)
+	}
+	if got := attrs["weheat.retry_count"].AsInt64(); got != 1 {
+		t.Errorf("retry_count = %d, want 1", got)
+	}
+	if got := attrs["weheat.heat_pump.id"].AsString(); got != "hp" {
+		t.Errorf("heat_pump.id = %q, want hp", got)
+	}
+	if durations, errors := rec.sums(t); durations != 1 || errors != 0 {
+		t.Errorf("durations = %d, errors = %d; want 1, 0", durations, errors)
+	}
+}
+
+func TestTelemetryRecordsFailure(t *testing.T) {
+	rec, opts := newOtelRecorder()
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.NotFound(w, nil)
+	}), opts...)
+
+	if _, err := client.GetHeatPump(testContext(t), "missing", weheat.RequestOptions{}); err == nil {
+		t.Fatal("want error")
+	}
+	span := rec.onlySpan(t)
+	if span.Status().Code != codes.Error {
+		t.Errorf("span status = %v, want error", span.Status())
+	}
+	if got := spanAttrs(span)["error.type"].AsString(); got != "404" {
+		t.Errorf("error.type = %q, want 404", got)
+	}
+	if durations, errors := rec.sums(t); durations != 1 || errors != 1 {
+		t.Errorf("durations = %d, errors = %d; want 1, 1", durations, errors)
+	}
+}
+
+func TestTelemetryCoversStreamedBody(t *testing.T) {
+	rec, opts := newOtelRecorder()
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"heatPumpId":"hp","timestamp":"2024-01-01T00:00:00Z"},{"timestamp":false}]`)
+	}), opts...)
+
+	var streamErr error
+	for _, err := range client.StreamRawLogs(testContext(t), "hp", weheat.LogQuery{}) {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if ended := len(rec.spans.Ended()); ended != 0 {
+			t.Fatalf("span ended while the stream was being read")
+		}
+	}
+	if streamErr == nil {
+		t.Fatal("want decode error")
+	}
+	span := rec.onlySpan(t)
+	if span.Status().Code != codes.Error {
+		t.Errorf("span status = %v, want error for the decode failure", span.Status())
+	}
+	attrs := spanAttrs(span)
+	if got := attrs["http.response.status_code"].AsInt64(); got != 200 {
+		t.Errorf("status_code = %d, want 200", got)
+	}
+	if got := attrs["error.type"].AsString(); got != "_OTHER" {
+		t.Errorf("error.type = %q, want _OTHER", got)
+	}
+	if durations, errors := rec.sums(t); durations != 1 || errors != 1 {
+		t.Errorf("durations = %d, errors = %d; want 1, 1", durations, errors)
+	}
+}
+
+func TestTelemetryRecordsCacheHits(t *testing.T) {
+	var calls atomic.Int32
+	rec, opts := newOtelRecorder()
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}), append(opts, weheat.WithCache(weheat.NewMemoryCache(), weheat.DefaultCacheTTLs()))...)
+
+	for range 2 {
+		if _, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("server saw %d requests, want 1", got)
+	}
+	spans := rec.spans.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended spans = %d, want one per call", len(spans))
+	}
+	for i, want := range []bool{false, true} {
+		if got := spanAttrs(spans[i])["weheat.cache_hit"].AsBool(); got != want {
+			t.Errorf("span %d cache_hit = %v, want %v", i, got, want)
+		}
+	}
+	if durations, errors := rec.sums(t); durations != 2 || errors != 0 {
+		t.Errorf("durations = %d, errors = %d; want 2, 0", durations, errors)
+	}
+}
+
+func TestTelemetryRecordsCoalescedCallers(t *testing.T) {
+	release := make(chan struct{})
+	rec, opts := newOtelRecorder()
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"id":"hp"}`)
+	}), append(opts, weheat.WithRequestCoalescing())...)
+	ctx := testContext(t)
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetHeatPump(ctx, "hp", weheat.RequestOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	spans := rec.spans.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended spans = %d, want one per caller", len(spans))
+	}
+	coalesced := 0
+	for _, span := range spans {
+		attrs := spanAttrs(span)
+		if attrs["weheat.coalesced"].AsBool() {
+			coalesced++
+		}
+		if got := attrs["http.response.status_code"].AsInt64(); got != 200 {
+			t.Errorf("status_code = %d, want 200", got)
+		}
+	}
+	if coalesced != 2 {
+		t.Errorf("coalesced spans = %d, want 2", coalesced)
+	}
+	if durations, errors := rec.sums(t); durations != 3 || errors != 0 {
+		t.Errorf("durations = %d, errors = %d; want 3, 0", durations, errors)
+	}
+}
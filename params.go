@@ -6,6 +6,8 @@ import "time"
 type RequestOptions struct {
 	XVersion        string
 	XBackendVersion string
+	// NoCache skips the response cache lookup; the fresh response is still stored.
+	NoCache bool
 }
 
 // LogInterval defines log aggregation granularity.
@@ -41,6 +43,8 @@ type ListHeatPumpsParams struct {
 	OrganisationID string
 	Search         string
 	State          *DeviceState
+	// Prefetch makes Client.HeatPumps request the next page concurrently.
+	Prefetch bool
 	RequestOptions
 }
 
@@ -0,0 +1,169 @@
+package weheat_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+// simulatedCassette is recorded from weheattest.Server, not the real API;
+// regenerate it with go test -run TestReplayCassette -record.
+const simulatedCassette = "testdata/simulated-heatpump.json"
+
+var recordCassette = flag.Bool("record", false, "re-record "+simulatedCassette+" from weheattest.Server")
+
+// recordSimulatedCassette records the calls TestReplayCassette replays
+// through weheattest.Recorder against a fake server with a fixed clock.
+func recordSimulatedCassette(t *testing.T) {
+	t.Helper()
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	srv := weheattest.NewServer(weheattest.ServerConfig{Clock: func() time.Time { return now }})
+	defer srv.Close()
+	rec := weheattest.NewRecorder(nil)
+	client, err := srv.NewClient(weheat.WithHTTPClient(rec.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	start, hours := now.Add(-5*time.Minute), now.Add(-3*time.Hour)
+	calls := []func() error{
+		func() error { _, err := client.GetUserMe(ctx, weheat.RequestOptions{}); return err },
+		func() error { _, err := client.ListHeatPumps(ctx, weheat.ListHeatPumpsParams{}); return err },
+		func() error { _, err := client.GetHeatPump(ctx, defaultPumpID, weheat.RequestOptions{}); return err },
+		func() error { _, err := client.GetLatestLog(ctx, defaultPumpID, weheat.RequestOptions{}); return err },
+		func() error {
+			_, err := client.GetRawLogs(ctx, defaultPumpID, weheat.LogQuery{StartTime: &start, EndTime: &now})
+			return err
+		},
+		func() error {
+			_, err := client.GetLogs(ctx, defaultPumpID, weheat.LogQuery{StartTime: &hours, EndTime: &now, Interval: weheat.LogIntervalHour})
+			return err
+		},
+		func() error {
+			_, err := client.GetEnergyTotals(ctx, defaultPumpID, weheat.RequestOptions{})
+			return err
+		},
+	}
+	for _, call := range calls {
+		if err := call(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Save(simulatedCassette); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func replayClient(t *testing.T, rep *weheattest.Replayer) *weheat.Client {
+	t.Helper()
+	client, err := weheat.NewClient(
+		weheat.WithHTTPClient(rep.Client()),
+		weheat.WithTokenSource(weheat.StaticToken("token")),
+		weheat.WithRetryPolicy(weheat.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestReplayCassette(t *testing.T) {
+	if *recordCassette {
+		recordSimulatedCassette(t)
+	}
+	rep, err := weheattest.LoadReplayer(simulatedCassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := replayClient(t, rep)
+	ctx := testContext(t)
+
+	pumps, err := client.ListHeatPumps(ctx, weheat.ListHeatPumpsParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pumps.Data) != 1 || pumps.Data[0].ID != defaultPumpID {
+		t.Fatalf("pumps = %+v", pumps.Data)
+	}
+
+	hp := weheat.NewHeatPump(client, defaultPumpID)
+	if err := hp.RefreshLogs(ctx, weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hp.RefreshEnergy(ctx, weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if state := hp.HeatPumpState(); state == nil || *state != weheat.HeatPumpStateHeating {
+		t.Errorf("state = %v, want heating", state)
+	}
+	if cop := hp.COP(); cop == nil || *cop < 1 {
+		t.Errorf("COP = %v", cop)
+	}
+	if total := hp.EnergyTotal(); total == nil || *total <= 0 {
+		t.Errorf("EnergyTotal = %v", total)
+	}
+
+	start := time.Date(2024, 1, 15, 11, 55, 0, 0, time.UTC)
+	end := start.Add(5 * time.Minute)
+	logs, err := client.GetRawLogs(ctx, defaultPumpID, weheat.LogQuery{StartTime: &start, EndTime: &end})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 10 {
+		t.Errorf("raw logs = %d, want 10", len(logs))
+	}
+
+	// Requests the cassette never saw fail instead of reaching the network.
+	if _, err := client.GetHeatPump(ctx, "other", weheat.RequestOptions{}); !errors.Is(err, weheattest.ErrNoInteraction) {
+		t.Errorf("unrecorded request err = %v, want ErrNoInteraction", err)
+	}
+}
+
+func TestRecorderSanitizesAndReplays(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+	rec := weheattest.NewRecorder(nil)
+	client, err := srv.NewClient(weheat.WithHTTPClient(rec.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t)
+	live, err := client.GetUserMe(ctx, weheat.RequestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := rec.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	cassette, err := weheattest.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 1 {
+		t.Fatalf("recorded %d interactions, want 1", len(cassette.Interactions))
+	}
+	in := cassette.Interactions[0]
+	if in.Request.Header.Get("Authorization") != "" {
+		t.Error("Authorization header recorded")
+	}
+	if strings.Contains(in.Response.Body, *live.Email) {
+		t.Error("email recorded unredacted")
+	}
+
+	replayed, err := replayClient(t, weheattest.NewReplayer(cassette)).GetUserMe(ctx, weheat.RequestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ID != live.ID {
+		t.Errorf("replayed ID = %q, want %q", replayed.ID, live.ID)
+	}
+}
@@ -0,0 +1,76 @@
+package weheat_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+func fastRetries(attempts int) weheat.ClientOption {
+	return weheat.WithRetryPolicy(weheat.RetryPolicy{MaxAttempts: attempts, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+}
+
+func TestRetryRecoversFromTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"id":"hp"}`)
+		}
+	}), fastRetries(4))
+
+	if _, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}), fastRetries(3))
+
+	_, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{})
+	var retryErr *weheat.RetryError
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 3 {
+		t.Fatalf("err = %v, want RetryError after 3 attempts", err)
+	}
+	if !errors.Is(err, weheat.ErrServerError) {
+		t.Fatalf("err = %v, want ErrServerError", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("attempts = %d, want 3", calls.Load())
+	}
+}
+
+func TestRetryAfterBeyondMaxBackoffGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}), fastRetries(4))
+
+	start := time.Now()
+	_, err := client.GetHeatPump(testContext(t), "hp", weheat.RequestOptions{})
+	if !errors.Is(err, weheat.ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if calls.Load() != 1 || time.Since(start) > time.Second {
+		t.Fatalf("attempts = %d after %v; want one attempt and no wait", calls.Load(), time.Since(start))
+	}
+}
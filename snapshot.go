@@ -0,0 +1,158 @@
+package weheat
+
+import (
+	"reflect"
+	"time"
+)
+
+// HeatPumpSnapshot is a point-in-time copy of every HeatPump metric, taken
+// from a single log and energy total so the values are consistent with each
+// other. It shares no memory with the HeatPump. Unknown values are nil.
+type HeatPumpSnapshot struct {
+	HeatPumpID      string         `json:"heatPumpId"`
+	Timestamp       *time.Time     `json:"timestamp,omitempty"`
+	IsOnline        *bool          `json:"isOnline,omitempty"`
+	Model           *HeatPumpModel `json:"model,omitempty"`
+	NominalMaxPower *float64       `json:"nominalMaxPower,omitempty"`
+
+	State                *HeatPumpState `json:"state,omitempty"`
+	CompressorRPM        *float64       `json:"compressorRpm,omitempty"`
+	CompressorPercentage *int           `json:"compressorPercentage,omitempty"`
+
+	WaterInletTemperature             *float64 `json:"waterInletTemperature,omitempty"`
+	WaterOutletTemperature            *float64 `json:"waterOutletTemperature,omitempty"`
+	WaterHouseInTemperature           *float64 `json:"waterHouseInTemperature,omitempty"`
+	AirInletTemperature               *float64 `json:"airInletTemperature,omitempty"`
+	AirOutletTemperature              *float64 `json:"airOutletTemperature,omitempty"`
+	ThermostatWaterSetpoint           *float64 `json:"thermostatWaterSetpoint,omitempty"`
+	ThermostatRoomTemperature         *float64 `json:"thermostatRoomTemperature,omitempty"`
+	ThermostatRoomTemperatureSetpoint *float64 `json:"thermostatRoomTemperatureSetpoint,omitempty"`
+	ThermostatOnOffState              *int     `json:"thermostatOnOffState,omitempty"`
+	DHWTopTemperature                 *float64 `json:"dhwTopTemperature,omitempty"`
+	DHWBottomTemperature              *float64 `json:"dhwBottomTemperature,omitempty"`
+
+	PowerInput  *float64 `json:"powerInput,omitempty"`
+	PowerOutput *float64 `json:"powerOutput,omitempty"`
+	COP         *float64 `json:"cop,omitempty"`
+
+	DHWFlowVolume            *float64 `json:"dhwFlowVolume,omitempty"`
+	CentralHeatingFlowVolume *float64 `json:"centralHeatingFlowVolume,omitempty"`
+
+	IndoorUnitWaterPumpState      *bool `json:"indoorUnitWaterPumpState,omitempty"`
+	IndoorUnitAuxiliaryPumpState  *bool `json:"indoorUnitAuxiliaryPumpState,omitempty"`
+	IndoorUnitDHWValveOrPumpState *bool `json:"indoorUnitDhwValveOrPumpState,omitempty"`
+	IndoorUnitGasBoilerState      *bool `json:"indoorUnitGasBoilerState,omitempty"`
+	IndoorUnitElectricHeaterState *bool `json:"indoorUnitElectricHeaterState,omitempty"`
+
+	EnergyInHeating     *float64 `json:"energyInHeating,omitempty"`
+	EnergyInDHW         *float64 `json:"energyInDhw,omitempty"`
+	EnergyInDefrost     *float64 `json:"energyInDefrost,omitempty"`
+	EnergyInDefrostDHW  *float64 `json:"energyInDefrostDhw,omitempty"`
+	EnergyInDefrostCH   *float64 `json:"energyInDefrostCh,omitempty"`
+	EnergyInCooling     *float64 `json:"energyInCooling,omitempty"`
+	EnergyOutHeating    *float64 `json:"energyOutHeating,omitempty"`
+	EnergyOutDHW        *float64 `json:"energyOutDhw,omitempty"`
+	EnergyOutDefrost    *float64 `json:"energyOutDefrost,omitempty"`
+	EnergyOutDefrostDHW *float64 `json:"energyOutDefrostDhw,omitempty"`
+	EnergyOutDefrostCH  *float64 `json:"energyOutDefrostCh,omitempty"`
+	EnergyOutCooling    *float64 `json:"energyOutCooling,omitempty"`
+	EnergyTotal         *float64 `json:"energyTotal,omitempty"`
+	EnergyOutput        *float64 `json:"energyOutput,omitempty"`
+}
+
+// Snapshot returns the current metrics as a HeatPumpSnapshot.
+func (h *HeatPump) Snapshot() HeatPumpSnapshot {
+	h.mu.RLock()
+	// Computing from a private copy keeps every metric on the same data
+	// while refreshes carry on.
+	at := &HeatPump{
+		id:              h.id,
+		lastLog:         h.lastLog,
+		energyTotals:    h.energyTotals,
+		nominalMaxPower: h.nominalMaxPower,
+		model:           h.model,
+	}
+	h.mu.RUnlock()
+
+	snapshot := HeatPumpSnapshot{
+		HeatPumpID:      at.id,
+		Model:           clonePtr(at.model),
+		NominalMaxPower: clonePtr(at.nominalMaxPower),
+
+		State:                at.HeatPumpState(),
+		CompressorRPM:        clonePtr(at.CompressorRPM()),
+		CompressorPercentage: at.CompressorPercentage(),
+
+		WaterInletTemperature:             clonePtr(at.WaterInletTemperature()),
+		WaterOutletTemperature:            clonePtr(at.WaterOutletTemperature()),
+		WaterHouseInTemperature:           clonePtr(at.WaterHouseInTemperature()),
+		AirInletTemperature:               clonePtr(at.AirInletTemperature()),
+		AirOutletTemperature:              clonePtr(at.AirOutletTemperature()),
+		ThermostatWaterSetpoint:           clonePtr(at.ThermostatWaterSetpoint()),
+		ThermostatRoomTemperature:         clonePtr(at.ThermostatRoomTemperature()),
+		ThermostatRoomTemperatureSetpoint: clonePtr(at.ThermostatRoomTemperatureSetpoint()),
+		ThermostatOnOffState:              clonePtr(at.ThermostatOnOffState()),
+		DHWTopTemperature:                 clonePtr(at.DHWTopTemperature()),
+		DHWBottomTemperature:              clonePtr(at.DHWBottomTemperature()),
+
+		PowerInput:  at.PowerInput(),
+		PowerOutput: at.PowerOutput(),
+		COP:         at.COP(),
+
+		DHWFlowVolume:            at.DHWFlowVolume(),
+		CentralHeatingFlowVolume: at.CentralHeatingFlowVolume(),
+
+		IndoorUnitWaterPumpState:      clonePtr(at.IndoorUnitWaterPumpState()),
+		IndoorUnitAuxiliaryPumpState:  clonePtr(at.IndoorUnitAuxiliaryPumpState()),
+		IndoorUnitDHWValveOrPumpState: clonePtr(at.IndoorUnitDHWValveOrPumpState()),
+		IndoorUnitGasBoilerState:      clonePtr(at.IndoorUnitGasBoilerState()),
+		IndoorUnitElectricHeaterState: clonePtr(at.IndoorUnitElectricHeaterState()),
+
+		EnergyInHeating:     clonePtr(at.EnergyInHeating()),
+		EnergyInDHW:         clonePtr(at.EnergyInDHW()),
+		EnergyInDefrost:     at.EnergyInDefrost(),
+		EnergyInDefrostDHW:  clonePtr(at.EnergyInDefrostDHW()),
+		EnergyInDefrostCH:   clonePtr(at.EnergyInDefrostCH()),
+		EnergyInCooling:     clonePtr(at.EnergyInCooling()),
+		EnergyOutHeating:    clonePtr(at.EnergyOutHeating()),
+		EnergyOutDHW:        clonePtr(at.EnergyOutDHW()),
+		EnergyOutDefrost:    at.EnergyOutDefrost(),
+		EnergyOutDefrostDHW: clonePtr(at.EnergyOutDefrostDHW()),
+		EnergyOutDefrostCH:  clonePtr(at.EnergyOutDefrostCH()),
+		EnergyOutCooling:    clonePtr(at.EnergyOutCooling()),
+		EnergyTotal:         at.EnergyTotal(),
+		EnergyOutput:        at.EnergyOutput(),
+	}
+	if log := at.lastLog; log != nil {
+		snapshot.Timestamp = timePtr(log.Timestamp)
+		snapshot.IsOnline = clonePtr(log.IsOnline)
+	}
+	return snapshot
+}
+
+// cloneStruct copies a model struct along with the values its pointer
+// fields point at, so the copy shares nothing with the original.
+func cloneStruct[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	out := *value
+	fields := reflect.ValueOf(&out).Elem()
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		if field.Kind() == reflect.Pointer && !field.IsNil() {
+			copied := reflect.New(field.Type().Elem())
+			copied.Elem().Set(field.Elem())
+			field.Set(copied)
+		}
+	}
+	return &out
+}
+
+func clonePtr[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
@@ -0,0 +1,480 @@
+package weheat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentSuffix      = ".seg"
+	segmentDayFormat   = "2006-01-02"
+	coverageFile       = "coverage.json"
+	maxStoreRecord     = 16 << 20
+	defaultStoreSettle = 15 * time.Minute
+)
+
+// LogStore is an embedded on-disk store for raw logs, log views and energy
+// views. Records are appended to one segment file per heat pump, series and
+// UTC day; range queries only open the segments they overlap, and each
+// segment's index lets them read just the records inside the range. When the
+// same timestamp is written more than once, the last write wins; rewriting a
+// record unchanged leaves the segment as it was. Heat pump IDs "." and ".."
+// are rejected.
+type LogStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// StoreQuery selects records from a LogStore. Start and End are inclusive; a
+// zero End means no upper bound. A positive Step downsamples the result to
+// the last record in each Step-sized bucket.
+type StoreQuery struct {
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// OpenLogStore opens (creating if needed) a store rooted at dir.
+func OpenLogStore(dir string) (*LogStore, error) {
+	if dir == "" {
+		return nil, errors.New("weheat: store directory required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LogStore{dir: dir}, nil
+}
+
+// AppendRawLogs stores raw log entries.
+func (s *LogStore) AppendRawLogs(heatPumpID string, logs []RawHeatPumpLog) error {
+	return appendRecords(s, heatPumpID, "raw", logs, rawLogTime)
+}
+
+// RawLogs returns stored raw log entries in time order.
+func (s *LogStore) RawLogs(heatPumpID string, query StoreQuery) ([]RawHeatPumpLog, error) {
+	return readRecords(s, heatPumpID, "raw", query, rawLogTime)
+}
+
+// AppendLogViews stores aggregated log views for an interval.
+func (s *LogStore) AppendLogViews(heatPumpID string, interval LogInterval, views []HeatPumpLogView) error {
+	return appendRecords(s, heatPumpID, "logs-"+string(interval), views, logViewTime)
+}
+
+// LogViews returns stored log views for an interval in time order.
+func (s *LogStore) LogViews(heatPumpID string, interval LogInterval, query StoreQuery) ([]HeatPumpLogView, error) {
+	return readRecords(s, heatPumpID, "logs-"+string(interval), query, logViewTime)
+}
+
+// AppendEnergyViews stores energy views for an interval.
+func (s *LogStore) AppendEnergyViews(heatPumpID string, interval EnergyInterval, views []EnergyView) error {
+	return appendRecords(s, heatPumpID, "energy-"+string(interval), views, energyViewTime)
+}
+
+// EnergyViews returns stored energy views for an interval in time order.
+func (s *LogStore) EnergyViews(heatPumpID string, interval EnergyInterval, query StoreQuery) ([]EnergyView, error) {
+	return readRecords(s, heatPumpID, "energy-"+string(interval), query, energyViewTime)
+}
+
+// seriesDir returns the directory holding one series of a heat pump. Both
+// names are escaped into single path elements, and IDs that would still
+// resolve outside the store are rejected.
+func (s *LogStore) seriesDir(heatPumpID string, series string) (string, error) {
+	switch heatPumpID {
+	case "", ".", "..":
+		return "", fmt.Errorf("weheat: invalid heat pump id %q", heatPumpID)
+	}
+	return filepath.Join(s.dir, url.PathEscape(heatPumpID), url.PathEscape(series)), nil
+}
+
+func rawLogTime(log RawHeatPumpLog) time.Time {
+	return log.Timestamp
+}
+
+func logViewTime(view HeatPumpLogView) time.Time {
+	if view.TimeBucket == nil {
+		return time.Time{}
+	}
+	return *view.TimeBucket
+}
+
+func energyViewTime(view EnergyView) time.Time {
+	if view.TimeBucket == nil {
+		return time.Time{}
+	}
+	return *view.TimeBucket
+}
+
+// appendRecords writes each record as "<unix nanos> <json>\n" to the segment
+// for its UTC day.
+func appendRecords[T any](s *LogStore, heatPumpID, series string, records []T, stamp func(T) time.Time) error {
+	dir, err := s.seriesDir(heatPumpID, series)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	segments := map[string]map[int64][]byte{}
+	for _, record := range records {
+		ts := stamp(record)
+		if ts.IsZero() {
+			return errors.New("weheat: record has no timestamp")
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		name := ts.UTC().Format(segmentDayFormat) + segmentSuffix
+		if segments[name] == nil {
+			segments[name] = map[int64][]byte{}
+		}
+		segments[name][ts.UnixNano()] = data
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, segment := range segments {
+		if err := writeSegment(filepath.Join(dir, name), segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecords[T any](s *LogStore, heatPumpID, series string, query StoreQuery, stamp func(T) time.Time) ([]T, error) {
+	dir, err := s.seriesDir(heatPumpID, series)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	startDay := query.Start.UTC().Format(segmentDayFormat)
+	endDay := ""
+	if !query.End.IsZero() {
+		endDay = query.End.UTC().Format(segmentDayFormat)
+	}
+	var out []T
+	for _, entry := range entries {
+		day, ok := strings.CutSuffix(entry.Name(), segmentSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if !query.Start.IsZero() && day < startDay {
+			continue
+		}
+		if endDay != "" && day > endDay {
+			continue
+		}
+		if out, err = readSegment(filepath.Join(dir, entry.Name()), query, out); err != nil {
+			return nil, err
+		}
+	}
+
+	// Segments are read in day order and each index is sorted, but sort
+	// anyway so a record filed under the wrong day cannot break the order.
+	slices.SortStableFunc(out, func(a, b T) int {
+		return stamp(a).Compare(stamp(b))
+	})
+	if query.Step > 0 {
+		out = downsample(out, query.Step, stamp)
+	}
+	return out, nil
+}
+
+// readSegment decodes the records of one segment that fall inside the query,
+// using the segment index to read only those records.
+func readSegment[T any](path string, query StoreQuery, out []T) ([]T, error) {
+	ix, rebuilt, err := loadIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	if rebuilt {
+		// Best effort: a read-only store still answers from the scan.
+		_ = ix.save(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	i := 0
+	if !query.Start.IsZero() {
+		i, _ = ix.search(query.Start.UnixNano())
+	}
+	for _, e := range ix.entries[i:] {
+		if !query.End.IsZero() && e.nanos > query.End.UnixNano() {
+			break
+		}
+		data, err := readRecord(f, e)
+		if err != nil {
+			return nil, err
+		}
+		var record T
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("weheat: corrupt record in %s: %w", path, err)
+		}
+		out = append(out, record)
+	}
+	return out, nil
+}
+
+// downsample keeps the last record of every step-sized bucket.
+func downsample[T any](records []T, step time.Duration, stamp func(T) time.Time) []T {
+	out := records[:0]
+	var bucket time.Time
+	for _, record := range records {
+		b := stamp(record).Truncate(step)
+		if len(out) > 0 && b.Equal(bucket) {
+			out[len(out)-1] = record
+			continue
+		}
+		bucket = b
+		out = append(out, record)
+	}
+	return out
+}
+
+type timeSpan struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// missing returns the parts of [start, end] not yet recorded as fetched.
+func (s *LogStore) missing(dir string, start time.Time, end time.Time) ([]timeSpan, error) {
+	s.mu.RLock()
+	covered, err := readCoverage(dir)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var gaps []timeSpan
+	cursor := start
+	for _, span := range covered {
+		if !span.End.After(cursor) {
+			continue
+		}
+		if span.Start.After(end) {
+			break
+		}
+		if span.Start.After(cursor) {
+			gaps = append(gaps, timeSpan{Start: cursor, End: span.Start})
+		}
+		cursor = span.End
+		if !cursor.Before(end) {
+			return gaps, nil
+		}
+	}
+	if cursor.Before(end) {
+		gaps = append(gaps, timeSpan{Start: cursor, End: end})
+	}
+	return gaps, nil
+}
+
+// markCovered records [start, end] as fetched.
+func (s *LogStore) markCovered(dir string, start time.Time, end time.Time) error {
+	if !end.After(start) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	covered, err := readCoverage(dir)
+	if err != nil {
+		return err
+	}
+	covered = append(covered, timeSpan{Start: start.UTC(), End: end.UTC()})
+	slices.SortFunc(covered, func(a, b timeSpan) int {
+		return a.Start.Compare(b.Start)
+	})
+	merged := covered[:1]
+	for _, span := range covered[1:] {
+		last := &merged[len(merged)-1]
+		if !span.Start.After(last.End) {
+			if span.End.After(last.End) {
+				last.End = span.End
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, coverageFile), data, 0o644)
+}
+
+func readCoverage(dir string) ([]timeSpan, error) {
+	data, err := os.ReadFile(filepath.Join(dir, coverageFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var covered []timeSpan
+	if err := json.Unmarshal(data, &covered); err != nil {
+		return nil, err
+	}
+	return covered, nil
+}
+
+// ReadThroughClient answers log queries from a LogStore, fetching from the
+// API only the parts of a range the store has not seen. Queries without both
+// a start and end time go straight to the API.
+type ReadThroughClient struct {
+	Client *Client
+	Store  *LogStore
+	// Settle keeps data newer than this from being marked as complete, so
+	// late samples are fetched again; defaults to 15 minutes.
+	Settle time.Duration
+}
+
+// GetRawLogs returns raw logs for the query range, reading through the store.
+func (r *ReadThroughClient) GetRawLogs(ctx context.Context, heatPumpID string, query LogQuery) ([]RawHeatPumpLog, error) {
+	if query.StartTime == nil || query.EndTime == nil {
+		return r.Client.GetRawLogs(ctx, heatPumpID, query)
+	}
+	fetch := func(ctx context.Context, span timeSpan) error {
+		q := query
+		q.StartTime, q.EndTime = timePtr(span.Start), timePtr(span.End)
+		logs, err := r.Client.GetRawLogs(ctx, heatPumpID, q)
+		if err != nil {
+			return err
+		}
+		return r.Store.AppendRawLogs(heatPumpID, logs)
+	}
+	dir, err := r.Store.seriesDir(heatPumpID, "raw")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.fill(ctx, dir, *query.StartTime, *query.EndTime, "", fetch); err != nil {
+		return nil, err
+	}
+	return r.Store.RawLogs(heatPumpID, StoreQuery{Start: *query.StartTime, End: *query.EndTime})
+}
+
+// GetLogs returns log views for the query range, reading through the store.
+func (r *ReadThroughClient) GetLogs(ctx context.Context, heatPumpID string, query LogQuery) ([]HeatPumpLogView, error) {
+	if query.StartTime == nil || query.EndTime == nil || query.Interval == "" {
+		return r.Client.GetLogs(ctx, heatPumpID, query)
+	}
+	fetch := func(ctx context.Context, span timeSpan) error {
+		q := query
+		q.StartTime, q.EndTime = timePtr(span.Start), timePtr(span.End)
+		views, err := r.Client.GetLogs(ctx, heatPumpID, q)
+		if err != nil {
+			return err
+		}
+		return r.Store.AppendLogViews(heatPumpID, query.Interval, views)
+	}
+	dir, err := r.Store.seriesDir(heatPumpID, "logs-"+string(query.Interval))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.fill(ctx, dir, *query.StartTime, *query.EndTime, query.Interval, fetch); err != nil {
+		return nil, err
+	}
+	return r.Store.LogViews(heatPumpID, query.Interval, StoreQuery{Start: *query.StartTime, End: *query.EndTime})
+}
+
+// GetEnergyLogs returns energy views for the query range, reading through the store.
+func (r *ReadThroughClient) GetEnergyLogs(ctx context.Context, heatPumpID string, query EnergyLogQuery) ([]EnergyView, error) {
+	if query.StartTime == nil || query.EndTime == nil || query.Interval == "" {
+		return r.Client.GetEnergyLogs(ctx, heatPumpID, query)
+	}
+	fetch := func(ctx context.Context, span timeSpan) error {
+		q := query
+		q.StartTime, q.EndTime = timePtr(span.Start), timePtr(span.End)
+		views, err := r.Client.GetEnergyLogs(ctx, heatPumpID, q)
+		if err != nil {
+			return err
+		}
+		return r.Store.AppendEnergyViews(heatPumpID, query.Interval, views)
+	}
+	dir, err := r.Store.seriesDir(heatPumpID, "energy-"+string(query.Interval))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.fill(ctx, dir, *query.StartTime, *query.EndTime, LogInterval(query.Interval), fetch); err != nil {
+		return nil, err
+	}
+	return r.Store.EnergyViews(heatPumpID, query.Interval, StoreQuery{Start: *query.StartTime, End: *query.EndTime})
+}
+
+// fill fetches every uncovered part of [start, end] and records what has
+// settled as covered. For aggregated series (a non-empty interval) each gap is
+// widened to whole UTC buckets, so a stored bucket is never built from part of
+// its range, and the bucket still in progress is left uncovered.
+func (r *ReadThroughClient) fill(ctx context.Context, dir string, start time.Time, end time.Time, interval LogInterval, fetch func(context.Context, timeSpan) error) error {
+	if r.Client == nil {
+		return ErrClientMissing
+	}
+	if r.Store == nil {
+		return errors.New("weheat: log store required")
+	}
+	settle := r.Settle
+	if settle <= 0 {
+		settle = defaultStoreSettle
+	}
+
+	gaps, err := r.Store.missing(dir, start, end)
+	if err != nil {
+		return err
+	}
+	settled := time.Now().Add(-settle)
+	if interval != "" {
+		if current, ok := bucketStart(settled, interval, time.UTC); ok {
+			settled = current
+		}
+	}
+	for _, gap := range gaps {
+		if interval != "" {
+			if first, ok := bucketStart(gap.Start, interval, time.UTC); ok {
+				gap.Start = first
+				if last, _ := bucketStart(gap.End, interval, time.UTC); last.Before(gap.End) {
+					gap.End = bucketEnd(last, interval)
+				}
+			}
+		}
+		if err := fetch(ctx, gap); err != nil {
+			return err
+		}
+		coveredEnd := gap.End
+		if coveredEnd.After(settled) {
+			coveredEnd = settled
+		}
+		if err := r.Store.markCovered(dir, gap.Start, coveredEnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
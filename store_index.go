@@ -0,0 +1,277 @@
+package weheat
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	indexSuffix    = ".idx"
+	indexEntrySize = 24
+)
+
+// segmentIndex locates the live record for every timestamp in a segment. On
+// disk it is the segment size it describes followed by (nanos, offset,
+// length) entries sorted by nanos, all little-endian int64s; offset and
+// length point at the record's JSON. An index whose size does not match its
+// segment is rebuilt by scanning the segment.
+type segmentIndex struct {
+	size    int64
+	entries []indexEntry
+}
+
+type indexEntry struct {
+	nanos  int64
+	offset int64
+	length int64
+}
+
+func indexPath(segment string) string {
+	return strings.TrimSuffix(segment, segmentSuffix) + indexSuffix
+}
+
+// search returns the position of the first entry at or after nanos.
+func (ix *segmentIndex) search(nanos int64) (int, bool) {
+	return slices.BinarySearchFunc(ix.entries, nanos, func(e indexEntry, n int64) int {
+		return cmp.Compare(e.nanos, n)
+	})
+}
+
+// live returns the number of segment bytes taken by live records.
+func (ix *segmentIndex) live() int64 {
+	var n int64
+	for _, e := range ix.entries {
+		n += recordLineSize(e.nanos, e.length)
+	}
+	return n
+}
+
+func recordLineSize(nanos, length int64) int64 {
+	return int64(len(strconv.FormatInt(nanos, 10))) + 1 + length + 1
+}
+
+func (ix *segmentIndex) save(segment string) error {
+	data := make([]byte, 8, 8+len(ix.entries)*indexEntrySize)
+	binary.LittleEndian.PutUint64(data, uint64(ix.size))
+	for _, e := range ix.entries {
+		data = binary.LittleEndian.AppendUint64(data, uint64(e.nanos))
+		data = binary.LittleEndian.AppendUint64(data, uint64(e.offset))
+		data = binary.LittleEndian.AppendUint64(data, uint64(e.length))
+	}
+	return writeFileAtomic(indexPath(segment), data, 0o644)
+}
+
+// loadIndex returns the index of a segment and whether it had to be rebuilt.
+// A missing segment has an empty index.
+func loadIndex(segment string) (*segmentIndex, bool, error) {
+	info, err := os.Stat(segment)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &segmentIndex{}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := os.ReadFile(indexPath(segment))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, err
+	}
+	if ix, ok := decodeIndex(data); ok && ix.size == info.Size() {
+		return ix, false, nil
+	}
+	ix, err := scanIndex(segment)
+	return ix, true, err
+}
+
+func decodeIndex(data []byte) (*segmentIndex, bool) {
+	if len(data) < 8 || (len(data)-8)%indexEntrySize != 0 {
+		return nil, false
+	}
+	ix := &segmentIndex{size: int64(binary.LittleEndian.Uint64(data))}
+	ix.entries = make([]indexEntry, 0, (len(data)-8)/indexEntrySize)
+	for rest := data[8:]; len(rest) > 0; rest = rest[indexEntrySize:] {
+		ix.entries = append(ix.entries, indexEntry{
+			nanos:  int64(binary.LittleEndian.Uint64(rest)),
+			offset: int64(binary.LittleEndian.Uint64(rest[8:])),
+			length: int64(binary.LittleEndian.Uint64(rest[16:])),
+		})
+	}
+	return ix, true
+}
+
+// scanIndex rebuilds an index by reading a segment in full. A torn final
+// line is left out of the indexed size so the next append overwrites it.
+func scanIndex(segment string) (*segmentIndex, error) {
+	f, err := os.Open(segment)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	latest := map[int64]indexEntry{}
+	reader := bufio.NewReaderSize(f, 64<<10)
+	ix := &segmentIndex{}
+	for {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			line, err = readLongLine(reader, line)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		prefix, data, ok := bytes.Cut(line[:len(line)-1], []byte{' '})
+		if !ok {
+			return nil, fmt.Errorf("weheat: corrupt record in %s", segment)
+		}
+		nanos, err := strconv.ParseInt(string(prefix), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("weheat: corrupt record in %s: %w", segment, err)
+		}
+		latest[nanos] = indexEntry{
+			nanos:  nanos,
+			offset: ix.size + int64(len(prefix)) + 1,
+			length: int64(len(data)),
+		}
+		ix.size += int64(len(line))
+	}
+	ix.entries = make([]indexEntry, 0, len(latest))
+	for _, e := range latest {
+		ix.entries = append(ix.entries, e)
+	}
+	slices.SortFunc(ix.entries, func(a, b indexEntry) int {
+		return cmp.Compare(a.nanos, b.nanos)
+	})
+	return ix, nil
+}
+
+func readLongLine(reader *bufio.Reader, first []byte) ([]byte, error) {
+	line := slices.Clone(first)
+	for len(line) <= maxStoreRecord {
+		more, err := reader.ReadSlice('\n')
+		line = append(line, more...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+	return nil, errors.New("weheat: stored record too large")
+}
+
+// writeSegment adds records, keyed by unix nanos, to a segment. Records
+// already stored with identical JSON are skipped, so refetching a range does
+// not grow the segment; changed records are appended and replace the old
+// entry in the index. Once superseded records take up more than half of the
+// segment it is rewritten in time order.
+func writeSegment(segment string, records map[int64][]byte) error {
+	ix, rebuilt, err := loadIndex(segment)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(segment, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	nanos := make([]int64, 0, len(records))
+	for n := range records {
+		nanos = append(nanos, n)
+	}
+	slices.Sort(nanos)
+
+	var buf bytes.Buffer
+	for _, n := range nanos {
+		data := records[n]
+		i, found := ix.search(n)
+		if found {
+			existing, err := readRecord(f, ix.entries[i])
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(existing, data) {
+				continue
+			}
+		}
+		prefix := strconv.FormatInt(n, 10)
+		entry := indexEntry{
+			nanos:  n,
+			offset: ix.size + int64(buf.Len()+len(prefix)) + 1,
+			length: int64(len(data)),
+		}
+		buf.WriteString(prefix)
+		buf.WriteByte(' ')
+		buf.Write(data)
+		buf.WriteByte('\n')
+		if found {
+			ix.entries[i] = entry
+		} else {
+			ix.entries = slices.Insert(ix.entries, i, entry)
+		}
+	}
+	if buf.Len() == 0 {
+		if rebuilt {
+			return ix.save(segment)
+		}
+		return nil
+	}
+
+	// Drop a torn line left by an interrupted write before appending.
+	if err := f.Truncate(ix.size); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(buf.Bytes(), ix.size); err != nil {
+		return err
+	}
+	ix.size += int64(buf.Len())
+	if ix.size > 2*ix.live() {
+		return compactSegment(f, segment, ix)
+	}
+	return ix.save(segment)
+}
+
+// compactSegment rewrites a segment with only its live records, in time
+// order, and saves the matching index.
+func compactSegment(f *os.File, segment string, ix *segmentIndex) error {
+	var buf bytes.Buffer
+	entries := make([]indexEntry, 0, len(ix.entries))
+	for _, e := range ix.entries {
+		data, err := readRecord(f, e)
+		if err != nil {
+			return err
+		}
+		prefix := strconv.FormatInt(e.nanos, 10)
+		entries = append(entries, indexEntry{
+			nanos:  e.nanos,
+			offset: int64(buf.Len()+len(prefix)) + 1,
+			length: e.length,
+		})
+		buf.WriteString(prefix)
+		buf.WriteByte(' ')
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(segment, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	compacted := &segmentIndex{size: int64(buf.Len()), entries: entries}
+	return compacted.save(segment)
+}
+
+func readRecord(f *os.File, e indexEntry) ([]byte, error) {
+	data := make([]byte, e.length)
+	if _, err := f.ReadAt(data, e.offset); err != nil {
+		return nil, fmt.Errorf("weheat: corrupt record in %s: %w", f.Name(), err)
+	}
+	return data, nil
+}
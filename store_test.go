@@ -0,0 +1,307 @@
+package weheat_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+func TestLogStoreRejectsEscapingIDs(t *testing.T) {
+	root := t.TempDir()
+	store, err := weheat.OpenLogStore(filepath.Join(root, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := []weheat.RawHeatPumpLog{{Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	for _, id := range []string{"", ".", ".."} {
+		if err := store.AppendRawLogs(id, logs); err == nil {
+			t.Errorf("AppendRawLogs(%q) succeeded", id)
+		}
+		if _, err := store.RawLogs(id, weheat.StoreQuery{}); err == nil {
+			t.Errorf("RawLogs(%q) succeeded", id)
+		}
+	}
+
+	// Separators are escaped into a single directory inside the store.
+	if err := store.AppendRawLogs("../escape", logs); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendLogViews("hp", weheat.LogInterval("/../../x"), []weheat.HeatPumpLogView{{TimeBucket: &logs[0].Timestamp}}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "store" {
+		t.Fatalf("store wrote outside its directory: %v", entries)
+	}
+	got, err := store.RawLogs("../escape", weheat.StoreQuery{})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("RawLogs = %v, %v", got, err)
+	}
+}
+
+func TestLogStoreRangesAndOverwrites(t *testing.T) {
+	store, err := weheat.OpenLogStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ten-minute samples spanning midnight, so two day segments are read.
+	base := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	var logs []weheat.RawHeatPumpLog
+	for i := range 12 {
+		logs = append(logs, weheat.RawHeatPumpLog{Timestamp: base.Add(time.Duration(i) * 10 * time.Minute), TAirIn: float64Ptr(float64(i))})
+	}
+	// Written out of order; reads come back sorted.
+	if err := store.AppendRawLogs("hp", logs[6:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendRawLogs("hp", logs[:6]); err != nil {
+		t.Fatal(err)
+	}
+	rewrite := logs[3]
+	rewrite.TAirIn = float64Ptr(99)
+	if err := store.AppendRawLogs("hp", []weheat.RawHeatPumpLog{rewrite}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.RawLogs("hp", weheat.StoreQuery{Start: logs[2].Timestamp, End: logs[8].Timestamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 7 || !got[0].Timestamp.Equal(logs[2].Timestamp) || !got[6].Timestamp.Equal(logs[8].Timestamp) {
+		t.Fatalf("range read %d logs from %v", len(got), got)
+	}
+	if *got[1].TAirIn != 99 {
+		t.Errorf("rewritten sample power = %v, want the last write", *got[1].TAirIn)
+	}
+
+	hourly, err := store.RawLogs("hp", weheat.StoreQuery{Step: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hourly) != 2 || !hourly[0].Timestamp.Equal(logs[5].Timestamp) || !hourly[1].Timestamp.Equal(logs[11].Timestamp) {
+		t.Fatalf("hourly = %+v, want the last sample of each hour", hourly)
+	}
+}
+
+func TestLogStoreSkipsUnchangedRecordsAndCompacts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := weheat.OpenLogStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var logs []weheat.RawHeatPumpLog
+	for i := range 30 {
+		logs = append(logs, weheat.RawHeatPumpLog{Timestamp: base.Add(time.Duration(i) * 30 * time.Second), TAirIn: float64Ptr(float64(i))})
+	}
+	if err := store.AppendRawLogs("hp", logs); err != nil {
+		t.Fatal(err)
+	}
+	segment := filepath.Join(dir, "hp", "raw", "2024-01-01.seg")
+	size := func() int64 {
+		t.Helper()
+		info, err := os.Stat(segment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	written := size()
+
+	// A refetched tail that has not changed leaves the segment alone.
+	for range 5 {
+		if err := store.AppendRawLogs("hp", logs[20:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := size(); got != written {
+		t.Fatalf("segment grew from %d to %d bytes on unchanged rewrites", written, got)
+	}
+
+	// Changed records are appended, then compacted away once stale.
+	for round := range 10 {
+		tail := slices.Clone(logs[20:])
+		for i := range tail {
+			tail[i].TAirIn = float64Ptr(float64(100 + round))
+		}
+		if err := store.AppendRawLogs("hp", tail); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := size(); got > 2*written {
+		t.Fatalf("segment is %d bytes after rewrites, want at most %d", got, 2*written)
+	}
+
+	check := func() {
+		t.Helper()
+		got, err := store.RawLogs("hp", weheat.StoreQuery{Start: logs[18].Timestamp, End: logs[21].Timestamp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 4 || *got[1].TAirIn != 19 || *got[2].TAirIn != 109 || *got[3].TAirIn != 109 {
+			t.Fatalf("range read %+v", got)
+		}
+	}
+	check()
+
+	// The index is rebuilt from the segment when lost or left behind by a
+	// torn write.
+	if err := os.Remove(filepath.Join(dir, "hp", "raw", "2024-01-01.idx")); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("1704110400000000000 {\"tim"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	check()
+	if err := store.AppendRawLogs("hp", []weheat.RawHeatPumpLog{{Timestamp: base.Add(time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	all, err := store.RawLogs("hp", weheat.StoreQuery{})
+	if err != nil || len(all) != 31 {
+		t.Fatalf("RawLogs = %d logs, %v", len(all), err)
+	}
+}
+
+func TestReadThroughClientFetchesOnlyMissingSpans(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	srv := weheattest.NewServer(weheattest.ServerConfig{Clock: func() time.Time { return now }})
+	defer srv.Close()
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	client, err := srv.NewClient(weheat.WithMiddleware(func(next weheat.Doer) weheat.Doer {
+		return weheat.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requested = append(requested, req.URL.Query().Get("startTime")+"/"+req.URL.Query().Get("endTime"))
+			mu.Unlock()
+			return next.Do(req)
+		})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := weheat.OpenLogStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &weheat.ReadThroughClient{Client: client, Store: store}
+	ctx := testContext(t)
+	at := func(hour, minute int) *time.Time {
+		v := time.Date(2024, 1, 15, hour, minute, 0, 0, time.UTC)
+		return &v
+	}
+	read := func(start, end *time.Time) []weheat.RawHeatPumpLog {
+		t.Helper()
+		mu.Lock()
+		requested = nil
+		mu.Unlock()
+		logs, err := rt.GetRawLogs(ctx, defaultPumpID, weheat.LogQuery{StartTime: start, EndTime: end})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return logs
+	}
+
+	if logs := read(at(10, 0), at(11, 0)); len(logs) == 0 || len(requested) != 1 {
+		t.Fatalf("first read: %d logs, requests %v", len(logs), requested)
+	}
+	read(at(10, 30), at(11, 30))
+	if len(requested) != 1 || requested[0] != "2024-01-15T11:00:00.000000+0000/2024-01-15T11:30:00.000000+0000" {
+		t.Fatalf("overlapping read requested %v, want only the uncovered half hour", requested)
+	}
+	logs := read(at(10, 0), at(11, 30))
+	if len(requested) != 0 {
+		t.Fatalf("covered read requested %v", requested)
+	}
+	want, err := client.GetRawLogs(ctx, defaultPumpID, weheat.LogQuery{StartTime: at(10, 0), EndTime: at(11, 30)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("store returned %d logs, API %d", len(logs), len(want))
+	}
+}
+
+func TestReadThroughClientFetchesWholeCalendarBuckets(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	srv := weheattest.NewServer(weheattest.ServerConfig{Clock: func() time.Time { return now }})
+	defer srv.Close()
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	client, err := srv.NewClient(weheat.WithMiddleware(func(next weheat.Doer) weheat.Doer {
+		return weheat.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requested = append(requested, req.URL.Query().Get("startTime")+"/"+req.URL.Query().Get("endTime"))
+			mu.Unlock()
+			return next.Do(req)
+		})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := weheat.OpenLogStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &weheat.ReadThroughClient{Client: client, Store: store}
+	ctx := testContext(t)
+	at := func(day, hour int) *time.Time {
+		v := time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC)
+		return &v
+	}
+	const wholeDays = "2024-01-11T00:00:00.000000+0000/2024-01-13T00:00:00.000000+0000"
+
+	views, err := rt.GetLogs(ctx, defaultPumpID, weheat.LogQuery{StartTime: at(11, 6), EndTime: at(12, 18), Interval: weheat.LogIntervalDay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 1 || requested[0] != wholeDays {
+		t.Fatalf("day views requested %v, want %s", requested, wholeDays)
+	}
+	want, err := client.GetLogs(ctx, defaultPumpID, weheat.LogQuery{StartTime: at(12, 0), EndTime: at(13, 0), Interval: weheat.LogIntervalDay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 1 || len(want) == 0 || !reflect.DeepEqual(views[0], want[0]) {
+		t.Fatalf("stored %d day views, want the whole day from the API", len(views))
+	}
+
+	requested = nil
+	energy, err := rt.GetEnergyLogs(ctx, defaultPumpID, weheat.EnergyLogQuery{StartTime: at(11, 6), EndTime: at(12, 18), Interval: weheat.EnergyIntervalDay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 1 || requested[0] != wholeDays {
+		t.Fatalf("day energy requested %v, want %s", requested, wholeDays)
+	}
+	wantEnergy, err := client.GetEnergyLogs(ctx, defaultPumpID, weheat.EnergyLogQuery{StartTime: at(12, 0), EndTime: at(13, 0), Interval: weheat.EnergyIntervalDay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(energy) != 1 || len(wantEnergy) == 0 || !reflect.DeepEqual(energy[0], wantEnergy[0]) {
+		t.Fatalf("stored %d day energy views, want the whole day from the API", len(energy))
+	}
+}
+
+func float64Ptr(v float64) *float64 { return &v }
@@ -0,0 +1,406 @@
+package weheat
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwksCacheTTL = time.Hour
+	// jwksRefetchInterval limits the refetches forced by unknown key IDs, so
+	// tokens with made-up kids cannot hammer the realm.
+	jwksRefetchInterval = 30 * time.Second
+)
+
+// TokenInfo holds the decoded claims of a Keycloak access token.
+type TokenInfo struct {
+	Subject           string
+	Issuer            string
+	Audience          []string
+	ClientID          string
+	PreferredUsername string
+	IssuedAt          time.Time
+	ExpiresAt         time.Time
+	Scopes            []string
+	RealmRoles        []string
+	// Claims holds every claim in the token payload.
+	Claims map[string]any
+}
+
+// ExpiresWithin reports whether the token expires within d of now.
+func (t *TokenInfo) ExpiresWithin(d time.Duration) bool {
+	if t == nil || t.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Until(t.ExpiresAt) <= d
+}
+
+// HasRealmRole reports whether the token carries the given realm role.
+func (t *TokenInfo) HasRealmRole(role string) bool {
+	if t == nil {
+		return false
+	}
+	for _, r := range t.RealmRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTokenInfo decodes the claims of a JWT access token without verifying
+// its signature.
+func ParseTokenInfo(accessToken string) (*TokenInfo, error) {
+	_, payload, _, err := splitJWT(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTokenInfo(payload)
+}
+
+// TokenCheck validates the claims of a verified token.
+type TokenCheck func(*TokenInfo) error
+
+// ExpectIssuer rejects tokens whose iss claim is not issuer.
+func ExpectIssuer(issuer string) TokenCheck {
+	return func(info *TokenInfo) error {
+		if info.Issuer != issuer {
+			return fmt.Errorf("weheat: token issuer %q, want %q", info.Issuer, issuer)
+		}
+		return nil
+	}
+}
+
+// ExpectAudience rejects tokens whose aud claim does not include audience.
+func ExpectAudience(audience string) TokenCheck {
+	return func(info *TokenInfo) error {
+		if !slices.Contains(info.Audience, audience) {
+			return fmt.Errorf("weheat: token audience %q does not include %q", info.Audience, audience)
+		}
+		return nil
+	}
+}
+
+// VerifyTokenInfo checks the token signature against the keys published at
+// jwksURL and rejects expired tokens before returning its claims. An unknown
+// key ID refetches the JWKS, so rotated realm keys are picked up. Issuer and
+// audience are only checked when passed as checks, e.g. ExpectIssuer.
+func VerifyTokenInfo(ctx context.Context, accessToken string, jwksURL string, checks ...TokenCheck) (*TokenInfo, error) {
+	header, payload, signature, err := splitJWT(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	var head struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(header, &head); err != nil {
+		return nil, fmt.Errorf("weheat: invalid token header: %w", err)
+	}
+
+	keys, err := fetchJWKS(ctx, jwksURL, false)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys.find(head.Kid, head.Alg)
+	if !ok {
+		if keys, err = fetchJWKS(ctx, jwksURL, true); err != nil {
+			return nil, err
+		}
+		key, ok = keys.find(head.Kid, head.Alg)
+	}
+	if !ok {
+		return nil, fmt.Errorf("weheat: no signing key %q in JWKS", head.Kid)
+	}
+	signed := accessToken[:strings.LastIndex(accessToken, ".")]
+	if err := verifyJWTSignature(head.Alg, key, []byte(signed), signature); err != nil {
+		return nil, err
+	}
+
+	info, err := decodeTokenInfo(payload)
+	if err != nil {
+		return nil, err
+	}
+	if !info.ExpiresAt.IsZero() && time.Now().After(info.ExpiresAt) {
+		return nil, errors.New("weheat: token expired")
+	}
+	for _, check := range checks {
+		if err := check(info); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// CurrentTokenInfo fetches a token from source and decodes its claims.
+func CurrentTokenInfo(ctx context.Context, source TokenSource) (*TokenInfo, error) {
+	if source == nil {
+		return nil, errors.New("weheat: token source required")
+	}
+	token, err := source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTokenInfo(token)
+}
+
+func splitJWT(token string) ([]byte, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, errors.New("weheat: access token is not a JWT")
+	}
+	decoded := make([][]byte, 3)
+	for i, part := range parts {
+		data, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("weheat: invalid JWT segment: %w", err)
+		}
+		decoded[i] = data
+	}
+	return decoded[0], decoded[1], decoded[2], nil
+}
+
+func decodeTokenInfo(payload []byte) (*TokenInfo, error) {
+	var claims struct {
+		Sub               string          `json:"sub"`
+		Iss               string          `json:"iss"`
+		Aud               json.RawMessage `json:"aud"`
+		Azp               string          `json:"azp"`
+		PreferredUsername string          `json:"preferred_username"`
+		Iat               float64         `json:"iat"`
+		Exp               float64         `json:"exp"`
+		Scope             string          `json:"scope"`
+		RealmAccess       struct {
+			Roles []string `json:"roles"`
+		} `json:"realm_access"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("weheat: invalid token claims: %w", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("weheat: invalid token claims: %w", err)
+	}
+
+	info := &TokenInfo{
+		Subject:           claims.Sub,
+		Issuer:            claims.Iss,
+		ClientID:          claims.Azp,
+		PreferredUsername: claims.PreferredUsername,
+		Scopes:            strings.Fields(claims.Scope),
+		RealmRoles:        claims.RealmAccess.Roles,
+		Claims:            raw,
+	}
+	if claims.Iat > 0 {
+		info.IssuedAt = time.Unix(int64(claims.Iat), 0)
+	}
+	if claims.Exp > 0 {
+		info.ExpiresAt = time.Unix(int64(claims.Exp), 0)
+	}
+	if len(claims.Aud) > 0 {
+		var single string
+		if err := json.Unmarshal(claims.Aud, &single); err == nil {
+			info.Audience = []string{single}
+		} else if err := json.Unmarshal(claims.Aud, &info.Audience); err != nil {
+			return nil, fmt.Errorf("weheat: invalid token audience: %w", err)
+		}
+	}
+	return info, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func (s *jsonWebKeySet) find(kid string, alg string) (crypto.PublicKey, bool) {
+	for _, key := range s.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if kid != "" && key.Kid != kid {
+			continue
+		}
+		if key.Alg != "" && alg != "" && key.Alg != alg {
+			continue
+		}
+		if pub, err := key.publicKey(); err == nil {
+			return pub, true
+		}
+	}
+	return nil, false
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("weheat: unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("weheat: unsupported key type %q", k.Kty)
+	}
+}
+
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("weheat: unsupported token algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	invalid := errors.New("weheat: invalid token signature")
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		if strings.HasPrefix(alg, "PS") {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, nil)
+		} else if strings.HasPrefix(alg, "RS") {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		} else {
+			return invalid
+		}
+		if err != nil {
+			return invalid
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return invalid
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return invalid
+		}
+		return nil
+	default:
+		return invalid
+	}
+}
+
+type cachedJWKS struct {
+	keys      *jsonWebKeySet
+	fetched   time.Time
+	refetched time.Time
+}
+
+var jwksCache = struct {
+	mu      sync.Mutex
+	entries map[string]cachedJWKS
+}{entries: map[string]cachedJWKS{}}
+
+// fetchJWKS returns the cached key set for jwksURL, fetching it when stale.
+// refetch bypasses a fresh cache unless it was refetched moments ago.
+func fetchJWKS(ctx context.Context, jwksURL string, refetch bool) (*jsonWebKeySet, error) {
+	if jwksURL == "" {
+		return nil, errors.New("weheat: JWKS URL required")
+	}
+
+	jwksCache.mu.Lock()
+	entry, ok := jwksCache.entries[jwksURL]
+	jwksCache.mu.Unlock()
+	fresh := ok && time.Since(entry.fetched) < jwksCacheTTL
+	if fresh && (!refetch || time.Since(entry.refetched) < jwksRefetchInterval) {
+		return entry.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := oauthHTTPClient(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp, body)
+	}
+
+	var keys jsonWebKeySet
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	jwksCache.mu.Lock()
+	entry = cachedJWKS{keys: &keys, fetched: now}
+	if refetch {
+		entry.refetched = now
+	}
+	jwksCache.entries[jwksURL] = entry
+	jwksCache.mu.Unlock()
+	return &keys, nil
+}
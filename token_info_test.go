@@ -0,0 +1,143 @@
+package weheat_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+// rotatingJWKS publishes one RSA key at a time and counts fetches.
+type rotatingJWKS struct {
+	mu      sync.Mutex
+	kid     string
+	key     *rsa.PrivateKey
+	fetches int
+}
+
+func (j *rotatingJWKS) rotate(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.mu.Lock()
+	j.kid, j.key = kid, key
+	j.mu.Unlock()
+}
+
+func (j *rotatingJWKS) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.fetches++
+	pub := j.key.PublicKey
+	_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": j.kid,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (j *rotatingJWKS) sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	j.mu.Lock()
+	key := j.key
+	j.mu.Unlock()
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (j *rotatingJWKS) count() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.fetches
+}
+
+func TestVerifyTokenInfoRefetchesRotatedKeys(t *testing.T) {
+	jwks := &rotatingJWKS{}
+	jwks.rotate(t, "old")
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+	ctx := testContext(t)
+	claims := map[string]any{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()}
+
+	if _, err := weheat.VerifyTokenInfo(ctx, jwks.sign(t, "old", claims), srv.URL); err != nil {
+		t.Fatalf("old key: %v", err)
+	}
+	jwks.rotate(t, "new")
+	info, err := weheat.VerifyTokenInfo(ctx, jwks.sign(t, "new", claims), srv.URL)
+	if err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if info.Subject != "user" {
+		t.Fatalf("Subject = %q", info.Subject)
+	}
+	if got := jwks.count(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", got)
+	}
+
+	// Unknown key IDs right after a refetch are served from the cache.
+	if _, err := weheat.VerifyTokenInfo(ctx, jwks.sign(t, "bogus", claims), srv.URL); err == nil {
+		t.Fatal("want error for unknown kid")
+	}
+	if got := jwks.count(); got != 2 {
+		t.Fatalf("JWKS fetched %d times after unknown kid, want 2", got)
+	}
+}
+
+func TestVerifyTokenInfoChecks(t *testing.T) {
+	jwks := &rotatingJWKS{}
+	jwks.rotate(t, "key")
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+	ctx := testContext(t)
+	token := jwks.sign(t, "key", map[string]any{
+		"iss": "https://realm.example",
+		"aud": []string{"account", "weheat-api"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	if _, err := weheat.VerifyTokenInfo(ctx, token, srv.URL,
+		weheat.ExpectIssuer("https://realm.example"), weheat.ExpectAudience("weheat-api")); err != nil {
+		t.Fatalf("matching checks: %v", err)
+	}
+	_, err := weheat.VerifyTokenInfo(ctx, token, srv.URL, weheat.ExpectIssuer("https://other.example"))
+	if err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Fatalf("issuer mismatch err = %v", err)
+	}
+	_, err = weheat.VerifyTokenInfo(ctx, token, srv.URL, weheat.ExpectAudience("other"))
+	if err == nil || !strings.Contains(err.Error(), "audience") {
+		t.Fatalf("audience mismatch err = %v", err)
+	}
+
+	expired := jwks.sign(t, "key", map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})
+	if _, err := weheat.VerifyTokenInfo(ctx, expired, srv.URL); err == nil {
+		t.Fatal("want error for expired token")
+	}
+}
@@ -0,0 +1,130 @@
+package weheat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNoToken is returned by a TokenStore that holds no token yet.
+var ErrNoToken = errors.New("weheat: no stored token")
+
+// TokenStore persists OAuth tokens so rotated refresh tokens survive restarts.
+type TokenStore interface {
+	Load(ctx context.Context) (*oauth2.Token, error)
+	Save(ctx context.Context, token *oauth2.Token) error
+}
+
+// TokenClearer is implemented by token stores that can forget their token.
+// RevokeToken and Logout clear stores that implement it.
+type TokenClearer interface {
+	Clear(ctx context.Context) error
+}
+
+// FileTokenStore keeps a token as JSON in a single file, replacing it atomically.
+type FileTokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore returns a store backed by the file at path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+// Load reads the stored token.
+func (s *FileTokenStore) Load(_ context.Context) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Save writes the token to a temporary file and renames it over the old one.
+func (s *FileTokenStore) Save(_ context.Context, token *oauth2.Token) error {
+	if token == nil {
+		return errors.New("weheat: token required")
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeFileAtomic(s.path, data, 0o600)
+}
+
+// Clear removes the token file.
+func (s *FileTokenStore) Clear(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// MemoryTokenStore keeps a token in memory.
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewMemoryTokenStore returns a store seeded with token, which may be nil.
+func NewMemoryTokenStore(token *oauth2.Token) *MemoryTokenStore {
+	return &MemoryTokenStore{token: copyToken(token)}
+}
+
+// Load returns a copy of the stored token.
+func (s *MemoryTokenStore) Load(_ context.Context) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == nil {
+		return nil, ErrNoToken
+	}
+	return copyToken(s.token), nil
+}
+
+// Save replaces the stored token.
+func (s *MemoryTokenStore) Save(_ context.Context, token *oauth2.Token) error {
+	if token == nil {
+		return errors.New("weheat: token required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = copyToken(token)
+	return nil
+}
+
+// Clear drops the stored token.
+func (s *MemoryTokenStore) Clear(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = nil
+	return nil
+}
+
+func copyToken(token *oauth2.Token) *oauth2.Token {
+	if token == nil {
+		return nil
+	}
+	clone := *token
+	return &clone
+}
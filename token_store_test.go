@@ -0,0 +1,189 @@
+package weheat_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	weheat "github.com/joshp123/weheat-golang"
+	"github.com/joshp123/weheat-golang/weheattest"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+	store := weheat.NewFileTokenStore(path)
+	ctx := testContext(t)
+
+	if _, err := store.Load(ctx); !errors.Is(err, weheat.ErrNoToken) {
+		t.Fatalf("empty Load err = %v, want ErrNoToken", err)
+	}
+	for _, refresh := range []string{"first", "second"} {
+		if err := store.Save(ctx, &oauth2.Token{AccessToken: "access", RefreshToken: refresh}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := store.Load(ctx)
+	if err != nil || got.RefreshToken != "second" {
+		t.Fatalf("Load = %+v, %v; want the last saved token", got, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("token file mode = %v, want 0600", mode)
+	}
+	// Saves go through a renamed temporary file, which must not linger.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("store directory holds %d files, want only the token", len(entries))
+	}
+
+	if err := store.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(ctx); !errors.Is(err, weheat.ErrNoToken) {
+		t.Fatalf("Load after Clear err = %v, want ErrNoToken", err)
+	}
+}
+
+func TestOAuthTokenSourceSavesRotatedToken(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+	ctx := testContext(t)
+	cfg := srv.OAuthConfig()
+	cfg.Store = weheat.NewFileTokenStore(filepath.Join(t.TempDir(), "token.json"))
+
+	source, err := weheat.OAuthTokenSource(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Token(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := cfg.Store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RefreshToken == "" || stored.RefreshToken == cfg.RefreshToken {
+		t.Fatalf("stored refresh token %q, want the rotated one", stored.RefreshToken)
+	}
+
+	// A new source prefers the stored token over the now-spent one in cfg.
+	restarted, err := weheat.OAuthTokenSource(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Token(ctx); err != nil {
+		t.Fatalf("Token after restart: %v", err)
+	}
+}
+
+type failingStore struct {
+	weheat.TokenStore
+	saves int
+}
+
+func (s *failingStore) Save(context.Context, *oauth2.Token) error {
+	s.saves++
+	return errors.New("disk full")
+}
+
+func TestOAuthTokenSourceSurvivesSaveFailure(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+	ctx := testContext(t)
+	store := &failingStore{TokenStore: weheat.NewMemoryTokenStore(nil)}
+	var reported []error
+	cfg := srv.OAuthConfig()
+	cfg.Store = store
+	cfg.OnSaveError = func(err error) { reported = append(reported, err) }
+
+	source, err := weheat.OAuthTokenSource(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if token, err := source.Token(ctx); err != nil || token == "" {
+			t.Fatalf("Token = %q, %v; want the access token despite the failed save", token, err)
+		}
+	}
+	if store.saves != 2 || len(reported) != 2 {
+		t.Fatalf("saves = %d, reported = %d; want the save retried and reported each time", store.saves, len(reported))
+	}
+}
+
+// loadSaveStore is a TokenStore without Clear.
+type loadSaveStore struct {
+	weheat.TokenStore
+}
+
+func TestRevokeTokenClearsStore(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+	ctx := testContext(t)
+	cfg := srv.OAuthConfig()
+	cfg.Store = weheat.NewMemoryTokenStore(nil)
+	source, err := weheat.OAuthTokenSource(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Token(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := cfg.Store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := weheat.RevokeToken(ctx, cfg, stored.RefreshToken, "refresh_token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Store.Load(ctx); !errors.Is(err, weheat.ErrNoToken) {
+		t.Fatalf("Load after revoke err = %v, want ErrNoToken", err)
+	}
+	cfg.Store, cfg.RefreshToken = nil, stored.RefreshToken
+	revoked, err := weheat.OAuthTokenSource(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := revoked.Token(ctx); err == nil {
+		t.Fatal("revoked refresh token still works")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	srv := weheattest.NewServer(weheattest.ServerConfig{})
+	defer srv.Close()
+	ctx := testContext(t)
+	refresh := srv.IssueRefreshToken()
+	cfg := srv.OAuthConfig()
+	cfg.Store = weheat.NewMemoryTokenStore(&oauth2.Token{RefreshToken: refresh})
+
+	if err := weheat.Logout(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Store.Load(ctx); !errors.Is(err, weheat.ErrNoToken) {
+		t.Fatalf("Load after logout err = %v, want ErrNoToken", err)
+	}
+
+	// Logging out again reports the dead session; a store without Clear
+	// is left alone.
+	store := loadSaveStore{weheat.NewMemoryTokenStore(&oauth2.Token{RefreshToken: refresh})}
+	cfg.Store = store
+	if err := weheat.Logout(ctx, cfg); !errors.Is(err, weheat.ErrTokenRevoked) {
+		t.Fatalf("second Logout err = %v, want ErrTokenRevoked", err)
+	}
+	if got, err := store.Load(ctx); err != nil || got.RefreshToken != refresh {
+		t.Fatalf("store without Clear holds %v, %v", got, err)
+	}
+}
@@ -0,0 +1,312 @@
+package weheat
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	defaultWatchEvery      = 30 * time.Second
+	defaultWatchDelay      = 5 * time.Second
+	defaultWatchMaxBackoff = 10 * time.Minute
+)
+
+// Watcher polls a heat pump's latest log and reports what changed between
+// samples, so callers don't need their own ticker and diffing loop.
+type Watcher struct {
+	// HeatPump is refreshed on every poll, so its accessors and Thresholds
+	// always see the latest sample.
+	HeatPump *HeatPump
+	// Energy also refreshes energy totals on every poll.
+	Energy bool
+	// Every is the polling period; defaults to the log's Interval, or 30
+	// seconds before the first sample. Polls are aligned to sample times.
+	Every time.Duration
+	// Delay is how long after a sample is due to poll for it, leaving the
+	// API time to ingest it; defaults to 5 seconds.
+	Delay time.Duration
+	// MaxBackoff caps the doubling poll period while the pump is offline or
+	// polls fail; defaults to 10 minutes.
+	MaxBackoff time.Duration
+	// Thresholds report a metric crossing a level.
+	Thresholds []Threshold
+	// RequestOptions are sent with every request.
+	RequestOptions RequestOptions
+}
+
+// Threshold watches a HeatPump metric, such as (*HeatPump).COP, for crossing
+// Level in either direction.
+type Threshold struct {
+	Name   string
+	Metric func(*HeatPump) *float64
+	Level  float64
+}
+
+// WatchEvent is one of StateChange, OnlineChange, DTCRaised,
+// ThresholdCrossed or PollError.
+type WatchEvent interface {
+	watchEvent()
+}
+
+// StateChange reports a transition between states from ParseHeatPumpState.
+// From is nil when the previous state code was not recognised.
+type StateChange struct {
+	Time time.Time
+	From *HeatPumpState
+	To   *HeatPumpState
+}
+
+// OnlineChange reports the pump going offline or coming back online.
+type OnlineChange struct {
+	Time   time.Time
+	Online bool
+}
+
+// DTCFlag is a decoded diagnostic trouble code flag.
+type DTCFlag string
+
+const (
+	DTCCompressorOff    DTCFlag = "compressor_off"
+	DTCDefrostForbidden DTCFlag = "defrost_forbidden"
+	DTCRequestService   DTCFlag = "request_service"
+	DTCUseHeatingCurve  DTCFlag = "use_heating_curve"
+	DTCDHWForbidden     DTCFlag = "dhw_forbidden"
+	DTCError            DTCFlag = "error"
+	DTCInactive         DTCFlag = "inactive"
+)
+
+// DTCRaised reports a new error code or newly set DTC flags. Flags holds
+// only the flags that were not set in the previous sample.
+type DTCRaised struct {
+	Time  time.Time
+	Code  int
+	Flags []DTCFlag
+}
+
+// ThresholdCrossed reports a Threshold metric moving to or above its level
+// (Rising) or back below it.
+type ThresholdCrossed struct {
+	Time      time.Time
+	Threshold Threshold
+	Value     float64
+	Rising    bool
+}
+
+// PollError reports a failed poll; the Watcher keeps going with backoff.
+type PollError struct {
+	Time time.Time
+	Err  error
+}
+
+func (StateChange) watchEvent()      {}
+func (OnlineChange) watchEvent()     {}
+func (DTCRaised) watchEvent()        {}
+func (ThresholdCrossed) watchEvent() {}
+func (PollError) watchEvent()        {}
+
+// Run polls until ctx is done, calling handle with each event in order. The
+// first sample only sets the baseline. It returns ctx.Err() once cancelled.
+func (w *Watcher) Run(ctx context.Context, handle func(WatchEvent)) error {
+	if w.HeatPump == nil {
+		return errors.New("weheat: watcher heat pump required")
+	}
+	if handle == nil {
+		return errors.New("weheat: watcher event handler required")
+	}
+
+	var (
+		prev     *RawHeatPumpLog
+		levels   []*float64
+		failures int
+	)
+	for {
+		log, err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			handle(PollError{Time: time.Now(), Err: err})
+		}
+		if log != nil {
+			current := w.levels()
+			if prev != nil {
+				w.diff(prev, log, levels, current, handle)
+			}
+			if prev == nil || log.Timestamp.After(prev.Timestamp) {
+				levels = current
+			}
+			prev = log
+		}
+
+		if err != nil || (log != nil && !online(log)) {
+			failures++
+		} else {
+			failures = 0
+		}
+		timer := time.NewTimer(w.next(prev, time.Now(), failures))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Watch runs the Watcher in a goroutine and delivers its events on the
+// returned channel, which is closed once ctx is done.
+func (w *Watcher) Watch(ctx context.Context) <-chan WatchEvent {
+	events := make(chan WatchEvent, 16)
+	go func() {
+		defer close(events)
+		err := w.Run(ctx, func(event WatchEvent) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			events <- PollError{Time: time.Now(), Err: err}
+		}
+	}()
+	return events
+}
+
+// poll refreshes the heat pump and returns its latest log, which is set even
+// when only refreshing the energy totals failed.
+func (w *Watcher) poll(ctx context.Context) (*RawHeatPumpLog, error) {
+	if err := w.HeatPump.RefreshLogs(ctx, w.RequestOptions); err != nil {
+		return nil, err
+	}
+	log := w.HeatPump.Log()
+	if w.Energy {
+		if err := w.HeatPump.RefreshEnergy(ctx, w.RequestOptions); err != nil {
+			return log, err
+		}
+	}
+	return log, nil
+}
+
+func (w *Watcher) levels() []*float64 {
+	levels := make([]*float64, len(w.Thresholds))
+	for i, threshold := range w.Thresholds {
+		if threshold.Metric != nil {
+			levels[i] = threshold.Metric(w.HeatPump)
+		}
+	}
+	return levels
+}
+
+// diff reports the changes between two polls. Online status is compared on
+// every poll; everything else only once a new sample has arrived.
+func (w *Watcher) diff(prev, log *RawHeatPumpLog, prevLevels, levels []*float64, handle func(WatchEvent)) {
+	if was, is := online(prev), online(log); was != is {
+		handle(OnlineChange{Time: log.Timestamp, Online: is})
+	}
+	if !log.Timestamp.After(prev.Timestamp) {
+		return
+	}
+
+	from, to := logState(prev), logState(log)
+	if to != nil && (from == nil || *from != *to) {
+		handle(StateChange{Time: log.Timestamp, From: from, To: to})
+	}
+
+	code := 0
+	if log.Error != nil {
+		code = *log.Error
+	}
+	var raised []DTCFlag
+	was := dtcFlags(prev)
+	for _, flag := range dtcFlags(log) {
+		if !slices.Contains(was, flag) {
+			raised = append(raised, flag)
+		}
+	}
+	if (code != 0 && (prev.Error == nil || *prev.Error != code)) || len(raised) > 0 {
+		handle(DTCRaised{Time: log.Timestamp, Code: code, Flags: raised})
+	}
+
+	for i, threshold := range w.Thresholds {
+		before, after := prevLevels[i], levels[i]
+		if before == nil || after == nil {
+			continue
+		}
+		rising := *before < threshold.Level && *after >= threshold.Level
+		falling := *before >= threshold.Level && *after < threshold.Level
+		if rising || falling {
+			handle(ThresholdCrossed{Time: log.Timestamp, Threshold: threshold, Value: *after, Rising: rising})
+		}
+	}
+}
+
+// next returns how long to wait before the following poll: just after the
+// next sample is due, or a doubling backoff after failures.
+func (w *Watcher) next(log *RawHeatPumpLog, now time.Time, failures int) time.Duration {
+	every := w.Every
+	if every <= 0 && log != nil && log.Interval > 0 {
+		every = time.Duration(log.Interval) * time.Second
+	}
+	if every <= 0 {
+		every = defaultWatchEvery
+	}
+	if failures > 0 {
+		limit := w.MaxBackoff
+		if limit <= 0 {
+			limit = defaultWatchMaxBackoff
+		}
+		backoff := every
+		for i := 1; i < failures && backoff < limit; i++ {
+			backoff *= 2
+		}
+		return min(backoff, limit)
+	}
+	if log == nil {
+		return every
+	}
+
+	delay := w.Delay
+	if delay <= 0 {
+		delay = defaultWatchDelay
+	}
+	due := log.Timestamp.Add(every + delay)
+	if !due.After(now) {
+		missed := now.Sub(due)/every + 1
+		due = due.Add(missed * every)
+	}
+	return due.Sub(now)
+}
+
+func online(log *RawHeatPumpLog) bool {
+	return log.IsOnline == nil || *log.IsOnline
+}
+
+func logState(log *RawHeatPumpLog) *HeatPumpState {
+	if log.State == nil {
+		return nil
+	}
+	return ParseHeatPumpState(*log.State)
+}
+
+func dtcFlags(log *RawHeatPumpLog) []DTCFlag {
+	var flags []DTCFlag
+	for _, f := range []struct {
+		set  *bool
+		flag DTCFlag
+	}{
+		{log.ErrorDecodedDtcCompressorOff, DTCCompressorOff},
+		{log.ErrorDecodedDtcDefrostForbidden, DTCDefrostForbidden},
+		{log.ErrorDecodedDtcRequestService, DTCRequestService},
+		{log.ErrorDecodedDtcUseHeatingCurve, DTCUseHeatingCurve},
+		{log.ErrorDecodedDtcDHWForbidden, DTCDHWForbidden},
+		{log.ErrorDecodedDtcError, DTCError},
+		{log.ErrorDecodedDtcInactive, DTCInactive},
+	} {
+		if isTrue(f.set) {
+			flags = append(flags, f.flag)
+		}
+	}
+	return flags
+}
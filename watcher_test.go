@@ -0,0 +1,102 @@
+package weheat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+func TestWatcherReportsChanges(t *testing.T) {
+	state := func(code int) *int { return &code }
+	temp := func(v float64) *float64 { return &v }
+	offline := false
+	samples := []weheat.RawHeatPumpLog{
+		{State: state(40), TAirIn: temp(20)},
+		{State: state(70), TAirIn: temp(30)},
+		{}, // served as a 500
+		{State: state(70), TAirIn: temp(30), Error: state(12)},
+		{State: state(70), TAirIn: temp(30), Error: state(12), IsOnline: &offline},
+	}
+	var (
+		mu    sync.Mutex
+		polls int
+	)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/heat-pumps/hp/logs/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		i := min(polls, len(samples)-1)
+		polls++
+		mu.Unlock()
+		if i == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sample := samples[i]
+		sample.HeatPumpID = "hp"
+		sample.Timestamp = time.Now().UTC()
+		json.NewEncoder(w).Encode(sample)
+	}))
+
+	watcher := &weheat.Watcher{
+		HeatPump:   weheat.NewHeatPump(client, "hp"),
+		Every:      20 * time.Millisecond,
+		Delay:      time.Millisecond,
+		MaxBackoff: 40 * time.Millisecond,
+		Thresholds: []weheat.Threshold{{
+			Name:   "air in",
+			Metric: func(hp *weheat.HeatPump) *float64 { return hp.Log().TAirIn },
+			Level:  25,
+		}},
+	}
+	ctx, cancel := context.WithCancel(testContext(t))
+	defer cancel()
+	var events []weheat.WatchEvent
+	for event := range watcher.Watch(ctx) {
+		events = append(events, event)
+		if change, ok := event.(weheat.OnlineChange); ok && !change.Online {
+			cancel()
+		}
+	}
+
+	var kinds []string
+	for _, event := range events {
+		switch e := event.(type) {
+		case weheat.StateChange:
+			if *e.From != weheat.HeatPumpStateStandby || *e.To != weheat.HeatPumpStateHeating {
+				t.Errorf("state change %v -> %v", *e.From, *e.To)
+			}
+			kinds = append(kinds, "state")
+		case weheat.ThresholdCrossed:
+			if !e.Rising || e.Value != 30 || e.Threshold.Name != "air in" {
+				t.Errorf("threshold crossed = %+v", e)
+			}
+			kinds = append(kinds, "threshold")
+		case weheat.PollError:
+			kinds = append(kinds, "error")
+		case weheat.DTCRaised:
+			if e.Code != 12 {
+				t.Errorf("DTC code = %d, want 12", e.Code)
+			}
+			kinds = append(kinds, "dtc")
+		case weheat.OnlineChange:
+			kinds = append(kinds, "offline")
+		}
+	}
+	want := []string{"state", "threshold", "error", "dtc", "offline"}
+	if len(kinds) != len(want) {
+		t.Fatalf("events = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("events = %v, want %v", kinds, want)
+		}
+	}
+}
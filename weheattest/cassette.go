@@ -0,0 +1,155 @@
+// Package weheattest provides HTTP fixtures for testing code that uses the
+// weheat client without network access or credentials.
+package weheattest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+// Cassette is a recorded sequence of HTTP interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one request and the response it received.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded HTTP request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// LoadCassette reads a cassette written by Recorder.Save.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, err
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to path as indented JSON.
+func (c *Cassette) Save(path string) error {
+	if c == nil {
+		return errors.New("weheattest: cassette required")
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// droppedHeaders never make it into a cassette. Content-Length goes too since
+// sanitizing may change the body.
+var droppedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "Content-Length"}
+
+// Sanitize removes credentials from an interaction and passes header values,
+// query parameters, form fields and JSON body fields through redact, such as
+// weheat.DefaultRedactor.
+func Sanitize(in *Interaction, redact weheat.Redactor) {
+	in.Request.Header = sanitizeHeader(in.Request.Header, redact)
+	in.Response.Header = sanitizeHeader(in.Response.Header, redact)
+	if u, err := url.Parse(in.Request.URL); err == nil && u.RawQuery != "" {
+		u.RawQuery = sanitizeValues(u.Query(), redact).Encode()
+		in.Request.URL = u.String()
+	}
+	in.Request.Body = sanitizeBody(in.Request.Body, in.Request.Header.Get("Content-Type"), redact)
+	in.Response.Body = sanitizeBody(in.Response.Body, in.Response.Header.Get("Content-Type"), redact)
+}
+
+func sanitizeHeader(header http.Header, redact weheat.Redactor) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	out := header.Clone()
+	for _, key := range droppedHeaders {
+		out.Del(key)
+	}
+	for key, values := range out {
+		for i, value := range values {
+			values[i] = redact(key, value)
+		}
+	}
+	return out
+}
+
+func sanitizeValues(values url.Values, redact weheat.Redactor) url.Values {
+	for key, list := range values {
+		for i, value := range list {
+			list[i] = redact(key, value)
+		}
+	}
+	return values
+}
+
+func sanitizeBody(body, contentType string, redact weheat.Redactor) string {
+	if body == "" {
+		return body
+	}
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return body
+		}
+		return sanitizeValues(values, redact).Encode()
+	}
+
+	var value any
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return body
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(sanitizeJSON("", value, redact)); err != nil {
+		return body
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func sanitizeJSON(key string, value any, redact weheat.Redactor) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, field := range v {
+			v[k] = sanitizeJSON(k, field, redact)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = sanitizeJSON(key, v[i], redact)
+		}
+		return v
+	case string:
+		return redact(key, v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,415 @@
+package weheattest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+// RealmPath is where the fake Keycloak realm is served.
+const RealmPath = "/auth/realms/Weheat"
+
+const signingKeyID = "weheattest"
+
+// Issuer returns the fake realm's issuer URL, for OAuthConfig.Issuer.
+func (s *Server) Issuer() string {
+	return s.URL + RealmPath
+}
+
+// OAuthConfig returns a config that authenticates against the fake realm
+// with a freshly issued refresh token.
+func (s *Server) OAuthConfig() weheat.OAuthConfig {
+	return weheat.OAuthConfig{
+		ClientID:     s.clientID,
+		Issuer:       s.Issuer(),
+		RefreshToken: s.IssueRefreshToken(),
+	}
+}
+
+// IssueRefreshToken returns a new valid refresh token.
+func (s *Server) IssueRefreshToken() string {
+	token := randomToken()
+	s.mu.Lock()
+	s.refreshTokens[token] = true
+	s.mu.Unlock()
+	return token
+}
+
+// AccessToken returns a new valid access token.
+func (s *Server) AccessToken() string {
+	token, _ := s.signAccessToken(time.Now())
+	return token
+}
+
+func (s *Server) registerRealm(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+RealmPath+"/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET "+RealmPath+"/protocol/openid-connect/auth", s.handleAuthorize)
+	mux.HandleFunc("POST "+RealmPath+"/protocol/openid-connect/auth/device", s.handleDeviceAuth)
+	mux.HandleFunc("GET "+RealmPath+"/device", s.handleDeviceApproval)
+	mux.HandleFunc("GET "+RealmPath+"/protocol/openid-connect/certs", s.handleCerts)
+	mux.HandleFunc("POST "+RealmPath+"/protocol/openid-connect/token", s.handleToken)
+	mux.HandleFunc("POST "+RealmPath+"/protocol/openid-connect/token/", s.handleToken)
+	mux.HandleFunc("POST "+RealmPath+"/protocol/openid-connect/revoke", s.handleRevoke)
+	mux.HandleFunc("POST "+RealmPath+"/protocol/openid-connect/logout", s.handleLogout)
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	issuer := s.Issuer()
+	endpoints := issuer + "/protocol/openid-connect"
+	writeJSON(w, http.StatusOK, weheat.OIDCConfiguration{
+		Issuer:                      issuer,
+		AuthorizationEndpoint:       endpoints + "/auth",
+		DeviceAuthorizationEndpoint: endpoints + "/auth/device",
+		TokenEndpoint:               endpoints + "/token",
+		RevocationEndpoint:          endpoints + "/revoke",
+		EndSessionEndpoint:          endpoints + "/logout",
+		JWKSURI:                     endpoints + "/certs",
+		ScopesSupported:             []string{"openid", "offline_access"},
+		GrantTypesSupported:         []string{"refresh_token", "password"},
+	})
+}
+
+func (s *Server) handleCerts(w http.ResponseWriter, _ *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kid": signingKeyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authCode is an issued authorization code awaiting exchange.
+type authCode struct {
+	redirectURI string
+	challenge   string
+	expiry      time.Time
+}
+
+// handleAuthorize approves every authorization request as the configured user
+// and redirects straight back with a code, standing in for the login page.
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	redirect, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" {
+		oauthError(w, http.StatusBadRequest, "invalid_request", "Invalid redirect_uri")
+		return
+	}
+	if query.Get("client_id") != s.clientID {
+		oauthError(w, http.StatusBadRequest, "invalid_client", "unknown client")
+		return
+	}
+	if query.Get("response_type") != "code" {
+		oauthError(w, http.StatusBadRequest, "unsupported_response_type", "Unsupported response_type")
+		return
+	}
+	challenge := query.Get("code_challenge")
+	if challenge != "" && query.Get("code_challenge_method") != "S256" {
+		oauthError(w, http.StatusBadRequest, "invalid_request", "Invalid code_challenge_method")
+		return
+	}
+
+	code := randomToken()
+	s.mu.Lock()
+	s.authCodes[code] = authCode{redirectURI: redirectURI, challenge: challenge, expiry: time.Now().Add(time.Minute)}
+	s.mu.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// redeemCode consumes an authorization code, checking the redirect URI and
+// the PKCE verifier against what the authorize request sent. It returns the
+// reason the code was rejected, or "".
+func (s *Server) redeemCode(form url.Values) string {
+	s.mu.Lock()
+	code, ok := s.authCodes[form.Get("code")]
+	delete(s.authCodes, form.Get("code"))
+	s.mu.Unlock()
+	switch {
+	case !ok || time.Now().After(code.expiry):
+		return "Code not valid"
+	case form.Get("redirect_uri") != code.redirectURI:
+		return "Incorrect redirect_uri"
+	case code.challenge != "":
+		digest := sha256.Sum256([]byte(form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(digest[:]) != code.challenge {
+			return "PKCE verification failed: Invalid code verifier"
+		}
+	}
+	return ""
+}
+
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceGrant is an issued device code awaiting approval.
+type deviceGrant struct {
+	userCode string
+	approved bool
+	interval time.Duration
+	lastPoll time.Time
+	expiry   time.Time
+}
+
+func (s *Server) handleDeviceAuth(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if !s.validClient(r) {
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "unknown client")
+		return
+	}
+	deviceCode := randomToken()
+	userCode := strings.ToUpper(randomToken()[:8])
+	const ttl = 10 * time.Minute
+	s.mu.Lock()
+	s.devices[deviceCode] = &deviceGrant{userCode: userCode, interval: s.deviceInterval, expiry: time.Now().Add(ttl)}
+	s.mu.Unlock()
+
+	verification := s.Issuer() + "/device"
+	writeJSON(w, http.StatusOK, map[string]any{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verification,
+		"verification_uri_complete": verification + "?user_code=" + url.QueryEscape(userCode),
+		"expires_in":                int(ttl / time.Second),
+		"interval":                  int(s.deviceInterval / time.Second),
+	})
+}
+
+// handleDeviceApproval approves the device code named by user_code, standing
+// in for the user signing in on another device.
+func (s *Server) handleDeviceApproval(w http.ResponseWriter, r *http.Request) {
+	userCode := r.URL.Query().Get("user_code")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, grant := range s.devices {
+		if grant.userCode == userCode && userCode != "" {
+			grant.approved = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeProblem(w, http.StatusNotFound, "unknown user code")
+}
+
+// pollDevice answers a device_code token request. Polls arriving much sooner
+// than the interval are told to slow down, which adds five seconds to it.
+func (s *Server) pollDevice(form url.Values) (code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deviceCode := form.Get("device_code")
+	grant, ok := s.devices[deviceCode]
+	switch {
+	case !ok:
+		return "invalid_grant", "Device code not valid"
+	case time.Now().After(grant.expiry):
+		delete(s.devices, deviceCode)
+		return "expired_token", "Device code is expired"
+	}
+	now := time.Now()
+	early := !grant.lastPoll.IsZero() && now.Sub(grant.lastPoll) < grant.interval/2
+	grant.lastPoll = now
+	switch {
+	case early:
+		grant.interval += 5 * time.Second
+		return "slow_down", "Slow down"
+	case !grant.approved:
+		return "authorization_pending", "The authorization request is still pending"
+	}
+	delete(s.devices, deviceCode)
+	return "", ""
+}
+
+func (s *Server) validClient(r *http.Request) bool {
+	clientID, _, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+	}
+	return clientID == s.clientID
+}
+
+// handleToken implements the authorization_code, device_code, refresh_token
+// and password grants. Refresh tokens rotate: each one can be used once.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if !s.validClient(r) {
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "unknown client")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		if reason := s.redeemCode(r.PostForm); reason != "" {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", reason)
+			return
+		}
+	case deviceCodeGrant:
+		if code, description := s.pollDevice(r.PostForm); code != "" {
+			oauthError(w, http.StatusBadRequest, code, description)
+			return
+		}
+	case "refresh_token":
+		token := r.PostForm.Get("refresh_token")
+		s.mu.Lock()
+		valid := s.refreshTokens[token]
+		delete(s.refreshTokens, token)
+		s.mu.Unlock()
+		if !valid {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+			return
+		}
+	case "password":
+		if r.PostForm.Get("username") != s.username || r.PostForm.Get("password") != s.password {
+			oauthError(w, http.StatusUnauthorized, "invalid_grant", "Invalid user credentials")
+			return
+		}
+	default:
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant_type")
+		return
+	}
+
+	access, err := s.signAccessToken(time.Now())
+	if err != nil {
+		oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token":  access,
+		"token_type":    "Bearer",
+		"expires_in":    int(s.accessTokenTTL / time.Second),
+		"refresh_token": s.IssueRefreshToken(),
+		"scope":         "openid offline_access",
+	})
+}
+
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		token = r.PostForm.Get("refresh_token")
+	}
+	s.mu.Lock()
+	delete(s.refreshTokens, token)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLogout ends the session behind a refresh token. Unlike revocation,
+// Keycloak rejects a token it no longer knows.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	token := r.PostForm.Get("refresh_token")
+	s.mu.Lock()
+	valid := s.refreshTokens[token]
+	delete(s.refreshTokens, token)
+	s.mu.Unlock()
+	if !valid {
+		oauthError(w, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) signAccessToken(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": signingKeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(map[string]any{
+		"iss":                s.Issuer(),
+		"sub":                s.user.ID,
+		"aud":                "account",
+		"azp":                s.clientID,
+		"preferred_username": s.username,
+		"iat":                now.Unix(),
+		"exp":                now.Add(s.accessTokenTTL).Unix(),
+		"jti":                randomToken(),
+		"scope":              "openid offline_access",
+		"realm_access":       map[string]any{"roles": []string{"default-roles-weheat"}},
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// authorize checks the request's bearer token against the realm's signing
+// key and expiry.
+func (s *Server) authorize(r *http.Request) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return errors.New("missing bearer token")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return errors.New("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return errors.New("malformed token")
+	}
+	if time.Now().Unix() >= claims.Exp {
+		return errors.New("token expired")
+	}
+	return nil
+}
+
+func oauthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
+
+func randomToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,100 @@
+package weheattest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+// Recorder is an http.RoundTripper that forwards requests to a real
+// transport and records every interaction, sanitized, for later replay.
+type Recorder struct {
+	// Transport sends the requests; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+	// Redact is applied to recorded values; nil means weheat.DefaultRedactor.
+	Redact weheat.Redactor
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns a recorder that forwards requests to transport.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport}
+}
+
+// RoundTrip sends req and records the exchange. The response body is read
+// fully so it can be stored and is handed back unchanged.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header,
+			Body:   string(reqBody),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       string(respBody),
+		},
+	}
+	redact := r.Redact
+	if redact == nil {
+		redact = weheat.DefaultRedactor
+	}
+	Sanitize(&in, redact)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Cassette returns a copy of the interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Save writes the recorded interactions to path.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+// Client returns an HTTP client that records through r, for use with
+// weheat.WithHTTPClient.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
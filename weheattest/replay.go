@@ -0,0 +1,114 @@
+package weheattest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrNoInteraction is returned when a replayed cassette holds no response for
+// a request.
+var ErrNoInteraction = errors.New("weheattest: no recorded interaction")
+
+// Matcher reports whether a recorded request answers req.
+type Matcher func(req *http.Request, recorded Request) bool
+
+// MatchMethodAndURL matches on method, path and query, ignoring the scheme
+// and host so a cassette can be replayed against any base URL. Query
+// parameter order does not matter.
+func MatchMethodAndURL(req *http.Request, recorded Request) bool {
+	if req.Method != recorded.Method {
+		return false
+	}
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return u.Path == req.URL.Path && u.Query().Encode() == req.URL.Query().Encode()
+}
+
+// Replayer is an http.RoundTripper that serves responses from a cassette.
+// Matching interactions are served in recorded order; once all have been
+// used the last one is repeated, so polling loops keep working.
+type Replayer struct {
+	// Match selects interactions; nil means MatchMethodAndURL.
+	Match Matcher
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer returns a replayer serving the cassette's interactions.
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{cassette: cassette, used: make([]bool, len(cassette.Interactions))}
+}
+
+// LoadReplayer reads the cassette at path and returns a replayer for it.
+func LoadReplayer(path string) (*Replayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(cassette), nil
+}
+
+// RoundTrip returns the recorded response for req.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	match := r.Match
+	if match == nil {
+		match = MatchMethodAndURL
+	}
+
+	r.mu.Lock()
+	found := -1
+	for i, in := range r.cassette.Interactions {
+		if !match(req, in.Request) {
+			continue
+		}
+		found = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if found >= 0 {
+		r.used[found] = true
+	}
+	r.mu.Unlock()
+
+	if found < 0 {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, req.Method, req.URL.RequestURI())
+	}
+	recorded := r.cassette.Interactions[found].Response
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Client returns an HTTP client that replays the cassette, for use with
+// weheat.WithHTTPClient.
+func (r *Replayer) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
@@ -0,0 +1,522 @@
+package weheattest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+const defaultPageSize = 10
+
+// ServerConfig configures a fake Weheat API.
+type ServerConfig struct {
+	// Pumps are served by the API; defaults to a single pump with DHW. Pumps
+	// without CommissionedAt get one a week before the server starts.
+	Pumps []*SimulatedPump
+	// User is returned by users/me; defaults to a consumer account.
+	User *weheat.ReadUserMe
+	// Clock sets the simulated current time; defaults to time.Now. Token
+	// expiry always uses the real clock.
+	Clock func() time.Time
+	// ClientID is the only OAuth client accepted; defaults to "weheat-test".
+	ClientID string
+	// Username and Password are accepted by the password grant; both
+	// default to "test".
+	Username string
+	Password string
+	// AccessTokenTTL defaults to five minutes.
+	AccessTokenTTL time.Duration
+	// DevicePollInterval is the polling interval handed out with device
+	// codes; defaults to five seconds.
+	DevicePollInterval time.Duration
+}
+
+// Server is an in-process fake of the Weheat API and its Keycloak realm.
+// Every request must carry an access token issued by the realm.
+type Server struct {
+	*httptest.Server
+
+	pumps          []*SimulatedPump
+	user           weheat.ReadUserMe
+	clock          func() time.Time
+	clientID       string
+	username       string
+	password       string
+	accessTokenTTL time.Duration
+	deviceInterval time.Duration
+	key            *rsa.PrivateKey
+
+	mu            sync.Mutex
+	refreshTokens map[string]bool
+	authCodes     map[string]authCode
+	devices       map[string]*deviceGrant
+	// totals caches each pump's energy up to the start of a UTC day, so
+	// GetEnergyTotals only integrates the days since its last call.
+	totals map[string]energyTotals
+}
+
+type energyTotals struct {
+	through time.Time
+	view    weheat.EnergyView
+}
+
+// NewServer starts a fake API. Call Close when done.
+func NewServer(cfg ServerConfig) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("weheattest: generating signing key: " + err.Error())
+	}
+	s := &Server{
+		pumps:          cfg.Pumps,
+		clock:          cfg.Clock,
+		clientID:       cfg.ClientID,
+		username:       cfg.Username,
+		password:       cfg.Password,
+		accessTokenTTL: cfg.AccessTokenTTL,
+		deviceInterval: cfg.DevicePollInterval,
+		key:            key,
+		refreshTokens:  map[string]bool{},
+		authCodes:      map[string]authCode{},
+		devices:        map[string]*deviceGrant{},
+	}
+	if s.clock == nil {
+		s.clock = time.Now
+	}
+	if s.clientID == "" {
+		s.clientID = "weheat-test"
+	}
+	if s.username == "" {
+		s.username = "test"
+	}
+	if s.password == "" {
+		s.password = "test"
+	}
+	if s.accessTokenTTL <= 0 {
+		s.accessTokenTTL = 5 * time.Minute
+	}
+	if s.deviceInterval <= 0 {
+		s.deviceInterval = 5 * time.Second
+	}
+
+	now := s.clock().UTC()
+	commissioned := now.Truncate(24 * time.Hour).Add(-7 * 24 * time.Hour)
+	if len(s.pumps) == 0 {
+		s.pumps = []*SimulatedPump{{
+			ID:           "5c4f1a52-8d0e-4b7a-9f55-0d2f4a7c9e31",
+			Name:         "Simulated pump",
+			Model:        weheat.HeatPumpModelBlackBirdP80,
+			SerialNumber: "WH-SIM-0001",
+			State:        weheat.DeviceStateActive,
+			HasDHW:       true,
+			Seed:         1,
+		}}
+	}
+	for _, pump := range s.pumps {
+		if pump.CommissionedAt.IsZero() {
+			pump.CommissionedAt = commissioned
+		}
+	}
+
+	if cfg.User != nil {
+		s.user = *cfg.User
+	} else {
+		first, last, email, language := "Test", "User", "test@example.com", "en"
+		s.user = weheat.ReadUserMe{
+			ID:        "b1e0c7a4-2f3d-4c59-8e61-7a9d0f2b3c4e",
+			FirstName: &first,
+			LastName:  &last,
+			Role:      weheat.RoleConsumer,
+			Email:     &email,
+			Language:  &language,
+			CreatedOn: commissioned,
+			UpdatedOn: commissioned,
+		}
+	}
+
+	mux := http.NewServeMux()
+	s.registerRealm(mux)
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/users/me", s.handleUserMe)
+	api.HandleFunc("GET /api/v1/heat-pumps", s.handleListHeatPumps)
+	api.HandleFunc("GET /api/v1/heat-pumps/{id}", s.handleHeatPump)
+	api.HandleFunc("GET /api/v1/heat-pumps/{id}/logs/latest", s.handleLatestLog)
+	api.HandleFunc("GET /api/v1/heat-pumps/{id}/logs/raw", s.handleRawLogs)
+	api.HandleFunc("GET /api/v1/heat-pumps/{id}/logs", s.handleLogs)
+	api.HandleFunc("GET /api/v1/energy-logs/{id}", s.handleEnergyLogs)
+	api.HandleFunc("GET /api/v1/energy-logs/{id}/total", s.handleEnergyTotals)
+	mux.Handle("/api/", s.requireAuth(api))
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// NewClient returns a client for the fake API that authenticates through
+// the fake realm. opts are applied after the base URL and token source.
+func (s *Server) NewClient(opts ...weheat.ClientOption) (*weheat.Client, error) {
+	source, err := weheat.OAuthTokenSource(context.Background(), s.OAuthConfig())
+	if err != nil {
+		return nil, err
+	}
+	base := []weheat.ClientOption{
+		weheat.WithBaseURL(s.URL),
+		weheat.WithTokenSource(source),
+	}
+	return weheat.NewClient(append(base, opts...)...)
+}
+
+// Pump returns the simulated pump with the given ID, or nil.
+func (s *Server) Pump(id string) *SimulatedPump {
+	for _, pump := range s.pumps {
+		if pump.ID == id {
+			return pump
+		}
+	}
+	return nil
+}
+
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.authorize(r); err != nil {
+			writeProblem(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleUserMe(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.user)
+}
+
+func (s *Server) handleListHeatPumps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := intParam(query.Get("page"), 1)
+	if err != nil || page < 1 {
+		writeProblem(w, http.StatusBadRequest, "page must be a positive integer")
+		return
+	}
+	pageSize, err := intParam(query.Get("pageSize"), defaultPageSize)
+	if err != nil || pageSize < 1 {
+		writeProblem(w, http.StatusBadRequest, "pageSize must be a positive integer")
+		return
+	}
+
+	var matched []weheat.ReadAllHeatPump
+	for _, pump := range s.pumps {
+		if !matchesFilter(pump, query) {
+			continue
+		}
+		detail := s.readHeatPump(pump)
+		matched = append(matched, weheat.ReadAllHeatPump{
+			ControlBoardID:  detail.ControlBoardID,
+			Name:            detail.Name,
+			Model:           detail.Model,
+			DHWType:         detail.DHWType,
+			BoilerType:      detail.BoilerType,
+			Status:          detail.Status,
+			CommissionedAt:  detail.CommissionedAt,
+			SerialNumber:    detail.SerialNumber,
+			PartNumber:      detail.PartNumber,
+			State:           detail.State,
+			ID:              detail.ID,
+			FirmwareVersion: ptr("2.4.1"),
+		})
+	}
+
+	total := len(matched)
+	totalPages := (total + pageSize - 1) / pageSize
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+	writeJSON(w, http.StatusOK, weheat.ReadAllHeatPumpPagedResponse{
+		Metadata: &weheat.PaginationMetadata{
+			TotalCount:  &total,
+			PageSize:    &pageSize,
+			CurrentPage: &page,
+			TotalPages:  &totalPages,
+		},
+		Data: matched[start:end],
+	})
+}
+
+func matchesFilter(pump *SimulatedPump, query map[string][]string) bool {
+	if models := query["Model"]; len(models) > 0 {
+		found := false
+		for _, model := range models {
+			if model == strconv.Itoa(int(pump.Model)) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if org := query["OrganisationId"]; len(org) > 0 && org[0] != pump.OrganisationID {
+		return false
+	}
+	if state := query["State"]; len(state) > 0 && state[0] != strconv.Itoa(int(pump.State)) {
+		return false
+	}
+	if search := query["Search"]; len(search) > 0 {
+		needle := strings.ToLower(search[0])
+		if !strings.Contains(strings.ToLower(pump.Name), needle) && !strings.Contains(strings.ToLower(pump.SerialNumber), needle) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) handleHeatPump(w http.ResponseWriter, r *http.Request) {
+	pump, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.readHeatPump(pump))
+}
+
+func (s *Server) readHeatPump(pump *SimulatedPump) weheat.ReadHeatPump {
+	dhw := weheat.DhwTypeUnavailable
+	if pump.HasDHW {
+		dhw = weheat.DhwTypeAvailable
+	}
+	boiler := weheat.BoilerTypeNone
+	status := weheat.HeatPumpStatusOffline
+	if now := s.clock(); pump.Online(now) {
+		status = weheat.HeatPumpStatus(*pump.LogAt(now).State)
+		if status == StateDefrost {
+			status = weheat.HeatPumpStatusDefrost
+		}
+	}
+	model := pump.Model
+	commissioned := pump.CommissionedAt
+	name := pump.Name
+	part := fmt.Sprintf("WH-%d", pump.Model)
+	return weheat.ReadHeatPump{
+		ControlBoardID: "cb-" + pump.ID,
+		Name:           &name,
+		Model:          &model,
+		DHWType:        &dhw,
+		BoilerType:     &boiler,
+		Status:         &status,
+		CommissionedAt: &commissioned,
+		SerialNumber:   pump.SerialNumber,
+		PartNumber:     &part,
+		State:          pump.State,
+		ID:             pump.ID,
+	}
+}
+
+func (s *Server) handleLatestLog(w http.ResponseWriter, r *http.Request) {
+	pump, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, pump.Latest(s.clock()))
+}
+
+func (s *Server) handleRawLogs(w http.ResponseWriter, r *http.Request) {
+	pump, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	start, end, ok := s.timeRange(w, r, time.Hour)
+	if !ok {
+		return
+	}
+	logs := pump.Logs(start, end)
+	if logs == nil {
+		logs = []weheat.RawHeatPumpLog{}
+	}
+	writeJSON(w, http.StatusOK, logs)
+}
+
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	pump, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	interval := weheat.LogInterval(r.URL.Query().Get("interval"))
+	if interval == "" {
+		interval = weheat.LogIntervalHour
+	}
+	start, end, ok := s.timeRange(w, r, 24*time.Hour)
+	if !ok {
+		return
+	}
+	views := weheat.AggregateLogs(pump.Logs(start, end), interval)
+	if views == nil {
+		writeProblem(w, http.StatusBadRequest, "unknown interval "+string(interval))
+		return
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) handleEnergyLogs(w http.ResponseWriter, r *http.Request) {
+	pump, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	interval := weheat.EnergyInterval(r.URL.Query().Get("interval"))
+	if interval == "" {
+		interval = weheat.EnergyIntervalHour
+	}
+	start, end, ok := s.timeRange(w, r, 24*time.Hour)
+	if !ok {
+		return
+	}
+	views := weheat.AggregateEnergyLogs(pump.Logs(start, end), interval)
+	if views == nil {
+		writeProblem(w, http.StatusBadRequest, "unknown interval "+string(interval))
+		return
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) handleEnergyTotals(w http.ResponseWriter, r *http.Request) {
+	pump, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	totals := s.energyTotals(pump, s.clock())
+	id := pump.ID
+	writeJSON(w, http.StatusOK, weheat.TotalEnergyAggregate{
+		HeatPumpID:              &id,
+		TotalEInHeating:         &totals.TotalEInHeating,
+		TotalEInStandby:         &totals.TotalEInStandby,
+		TotalEInDHW:             &totals.TotalEInDHW,
+		TotalEInHeatingDefrost:  &totals.TotalEInHeatingDefrost,
+		TotalEInDHWDefrost:      &totals.TotalEInDHWDefrost,
+		TotalEInCooling:         &totals.TotalEInCooling,
+		TotalEOutHeating:        &totals.TotalEOutHeating,
+		TotalEOutDHW:            &totals.TotalEOutDHW,
+		TotalEOutHeatingDefrost: &totals.TotalEOutHeatingDefrost,
+		TotalEOutDHWDefrost:     &totals.TotalEOutDHWDefrost,
+		TotalEOutCooling:        &totals.TotalEOutCooling,
+	})
+}
+
+// energyTotals integrates a pump's energy from CommissionedAt to now, a UTC
+// day at a time.
+func (s *Server) energyTotals(pump *SimulatedPump, now time.Time) weheat.EnergyView {
+	today := now.UTC().Truncate(24 * time.Hour)
+	s.mu.Lock()
+	cached, ok := s.totals[pump.ID]
+	s.mu.Unlock()
+	if !ok || cached.through.After(today) {
+		cached = energyTotals{through: pump.CommissionedAt}
+	}
+
+	for cached.through.Before(today) {
+		next := cached.through.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		addEnergy(&cached.view, pump.Logs(cached.through, next))
+		cached.through = next
+	}
+	s.mu.Lock()
+	if s.totals == nil {
+		s.totals = map[string]energyTotals{}
+	}
+	s.totals[pump.ID] = cached
+	s.mu.Unlock()
+
+	totals := cached.view
+	addEnergy(&totals, pump.Logs(cached.through, now))
+	return totals
+}
+
+func addEnergy(totals *weheat.EnergyView, logs []weheat.RawHeatPumpLog) {
+	for _, day := range weheat.AggregateEnergyLogs(logs, weheat.EnergyIntervalDay) {
+		totals.TotalEInHeating += day.TotalEInHeating
+		totals.TotalEInStandby += day.TotalEInStandby
+		totals.TotalEInDHW += day.TotalEInDHW
+		totals.TotalEInHeatingDefrost += day.TotalEInHeatingDefrost
+		totals.TotalEInDHWDefrost += day.TotalEInDHWDefrost
+		totals.TotalEInCooling += day.TotalEInCooling
+		totals.TotalEOutHeating += day.TotalEOutHeating
+		totals.TotalEOutDHW += day.TotalEOutDHW
+		totals.TotalEOutHeatingDefrost += day.TotalEOutHeatingDefrost
+		totals.TotalEOutDHWDefrost += day.TotalEOutDHWDefrost
+		totals.TotalEOutCooling += day.TotalEOutCooling
+	}
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*SimulatedPump, bool) {
+	pump := s.Pump(r.PathValue("id"))
+	if pump == nil {
+		writeProblem(w, http.StatusNotFound, "heat pump not found")
+		return nil, false
+	}
+	return pump, true
+}
+
+// timeRange reads startTime and endTime, defaulting to the span before now.
+func (s *Server) timeRange(w http.ResponseWriter, r *http.Request, span time.Duration) (time.Time, time.Time, bool) {
+	query := r.URL.Query()
+	end := s.clock()
+	if value := query.Get("endTime"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "invalid endTime")
+			return time.Time{}, time.Time{}, false
+		}
+		end = t
+	}
+	start := end.Add(-span)
+	if value := query.Get("startTime"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "invalid startTime")
+			return time.Time{}, time.Time{}, false
+		}
+		start = t
+	}
+	if end.Before(start) {
+		writeProblem(w, http.StatusBadRequest, "endTime is before startTime")
+		return time.Time{}, time.Time{}, false
+	}
+	// Like the real API, never report samples from the future.
+	if now := s.clock(); end.After(now) {
+		end = now
+	}
+	return start.UTC(), end.UTC(), true
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05.000000-0700", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func intParam(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(weheat.ProblemDetails{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,452 @@
+package weheattest
+
+import (
+	"math"
+	"time"
+
+	weheat "github.com/joshp123/weheat-golang"
+)
+
+// DefaultLogInterval is the spacing of simulated raw log samples.
+const DefaultLogInterval = 30 * time.Second
+
+// Raw log state codes produced by the simulator.
+const (
+	StateStandby    = 40
+	StateHeating    = 70
+	StateDHW        = 150
+	StateLegionella = 160
+	StateDefrost    = 200
+)
+
+// Window is a half-open time range [Start, End).
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains reports whether t falls inside the window.
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// Fault makes a simulated pump report a DTC error and stop its compressor
+// for the duration of the window.
+type Fault struct {
+	Window
+	Code int
+}
+
+// SimulatedPump generates plausible telemetry for one heat pump. Samples are
+// a pure function of time, so any period yields the same logs every time it
+// is requested.
+//
+// The pump heats in on/off cycles whose length follows a daily outdoor
+// temperature curve, defrosts periodically in cold weather, heats its DHW
+// tank every morning and evening when it has one, and runs a legionella
+// cycle on Sunday nights.
+type SimulatedPump struct {
+	ID           string
+	Name         string
+	Model        weheat.HeatPumpModel
+	SerialNumber string
+	State        weheat.DeviceState
+	// OrganisationID is matched by the OrganisationId list filter.
+	OrganisationID string
+	// HasDHW enables the domestic hot water tank and its schedule.
+	HasDHW bool
+	// CommissionedAt is the first moment with telemetry.
+	CommissionedAt time.Time
+	// Interval is the spacing of raw logs; defaults to DefaultLogInterval.
+	Interval time.Duration
+	// Location sets the local time for daily patterns; defaults to UTC.
+	Location *time.Location
+	// Seed varies the noise between pumps.
+	Seed uint64
+	// Outages are periods without telemetry, during which the pump is offline.
+	Outages []Window
+	Faults  []Fault
+}
+
+type pumpMode int
+
+const (
+	modeStandby pumpMode = iota
+	modeHeating
+	modeDHW
+	modeLegionella
+	modeHeatingDefrost
+	modeDHWDefrost
+)
+
+const heatingCycle = 40 * time.Minute
+
+func (p *SimulatedPump) interval() time.Duration {
+	if p.Interval > 0 {
+		return p.Interval
+	}
+	return DefaultLogInterval
+}
+
+func (p *SimulatedPump) location() *time.Location {
+	if p.Location != nil {
+		return p.Location
+	}
+	return time.UTC
+}
+
+// Online reports whether the pump sends telemetry at t.
+func (p *SimulatedPump) Online(t time.Time) bool {
+	if t.Before(p.CommissionedAt) {
+		return false
+	}
+	for _, outage := range p.Outages {
+		if outage.Contains(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Logs returns the raw logs sampled in [start, end), skipping outages.
+func (p *SimulatedPump) Logs(start, end time.Time) []weheat.RawHeatPumpLog {
+	step := p.interval()
+	t := start.Truncate(step)
+	if t.Before(start) {
+		t = t.Add(step)
+	}
+	var logs []weheat.RawHeatPumpLog
+	for ; t.Before(end); t = t.Add(step) {
+		if p.Online(t) {
+			logs = append(logs, p.LogAt(t))
+		}
+	}
+	return logs
+}
+
+// Latest returns the newest log at now. While the pump is offline it is the
+// last sample sent before the outage, marked offline.
+func (p *SimulatedPump) Latest(now time.Time) weheat.RawHeatPumpLog {
+	step := p.interval()
+	t := now.Truncate(step)
+	online := p.Online(t)
+	for moved := true; moved && !p.Online(t) && t.After(p.CommissionedAt); {
+		moved = false
+		for _, outage := range p.Outages {
+			if outage.Contains(t) {
+				t = outage.Start.Add(-time.Nanosecond).Truncate(step)
+				moved = true
+			}
+		}
+	}
+	log := p.LogAt(t)
+	log.IsOnline = &online
+	return log
+}
+
+// LogAt returns the sample taken at the log interval step containing t.
+func (p *SimulatedPump) LogAt(t time.Time) weheat.RawHeatPumpLog {
+	t = t.Truncate(p.interval()).UTC()
+	local := t.In(p.location())
+	outdoor := p.outdoor(t)
+	mode, demand, progress := p.mode(t, outdoor)
+
+	fault := 0
+	for _, f := range p.Faults {
+		if f.Contains(t) {
+			fault = f.Code
+			mode, demand = modeStandby, 0
+		}
+	}
+
+	log := weheat.RawHeatPumpLog{
+		HeatPumpID: p.ID,
+		Timestamp:  t,
+		Interval:   int(p.interval() / time.Second),
+	}
+
+	hour := float64(local.Hour()) + float64(local.Minute())/60
+	night := hour >= 23 || hour < 6
+	roomTarget := 21.0
+	if night {
+		roomTarget = 18.5
+	}
+	room := roomTarget - 0.4 + 0.3*math.Sin(2*math.Pi*hour/24) + 0.1*p.noise(t, 1)
+	supplyTarget := clamp(30+(20-outdoor)*0.6, 25, 50)
+	tankTop, tankBottom := p.tank(t, mode, progress)
+
+	var (
+		state                             int
+		rpm, fan, powerIn, powerOut       float64
+		waterOut, waterIn                 float64
+		chPWM, dhwPWM                     = 5, 5
+		pump, dhwValve, heater, onOff     bool
+		limiterType                       = 0
+		compressorIn, compressorOut       float64
+		pressureIn, pressureOut, airDelta float64
+	)
+	idleWater := clamp(room+3, 15, 35) + 0.2*p.noise(t, 2)
+
+	switch mode {
+	case modeStandby:
+		state = StateStandby
+		powerIn = 12 + 2*p.noise(t, 3)
+		waterOut = idleWater
+		waterIn = waterOut - 0.3
+		compressorIn, compressorOut = outdoor, outdoor+2
+		pressureIn, pressureOut = 8+outdoor*0.15, 9+outdoor*0.15
+	case modeHeating:
+		state = StateHeating
+		ramp := clamp(progress*8, 0.3, 1)
+		rpm = (1800 + 3000*demand) * ramp
+		if night && rpm > 3500 {
+			rpm, limiterType = 3500, 3
+		}
+		fan = 300 + 400*demand
+		cop := clamp(2.2+0.09*(outdoor+5)-(supplyTarget-30)*0.03, 1.8, 5.5)
+		powerIn = rpm*0.55 + 40 + 20*p.noise(t, 3)
+		powerOut = powerIn * cop
+		waterOut = idleWater + (supplyTarget-idleWater)*ramp + 0.3*p.noise(t, 4)
+		waterIn = waterOut - (3 + 3*demand*ramp)
+		chPWM, pump, onOff = 45, true, true
+		airDelta = -4 * ramp
+		compressorIn, compressorOut = outdoor-5, 55+rpm/200
+		pressureIn, pressureOut = 6+outdoor*0.15, 18+waterOut*0.25
+	case modeDHW, modeLegionella:
+		state = StateDHW
+		target := 55.0
+		if mode == modeLegionella {
+			state, target, heater = StateLegionella, 62, true
+		}
+		ramp := clamp(progress*10, 0.3, 1)
+		rpm = 4500 * ramp
+		fan = 650
+		powerIn = rpm*0.58 + 40 + 20*p.noise(t, 3)
+		powerOut = powerIn * clamp(2.0+0.05*(outdoor+5), 1.6, 3.5)
+		waterOut = clamp(tankBottom+8, 30, target)
+		waterIn = waterOut - 5
+		dhwPWM, pump, dhwValve = 60, true, true
+		airDelta = -5 * ramp
+		compressorIn, compressorOut = outdoor-6, 70+rpm/200
+		pressureIn, pressureOut = 6+outdoor*0.15, 25+waterOut*0.2
+	case modeHeatingDefrost, modeDHWDefrost:
+		state = StateDefrost
+		rpm = 3500
+		powerIn = 1800 + 30*p.noise(t, 3)
+		powerOut = -2500 + 50*p.noise(t, 4)
+		waterOut = supplyTarget - 4
+		waterIn = waterOut + 2
+		pump = true
+		if mode == modeDHWDefrost {
+			dhwPWM, dhwValve = 60, true
+		} else {
+			chPWM = 45
+		}
+		limiterType = 2
+		compressorIn, compressorOut = outdoor-2, 45
+		pressureIn, pressureOut = 12, 20
+	}
+
+	log.State = ptr(state)
+	log.TAirIn = ptr(round(outdoor, 2))
+	log.TAirOut = ptr(round(outdoor+airDelta, 2))
+	log.TWaterIn = ptr(round(waterIn, 2))
+	log.TWaterOut = ptr(round(waterOut, 2))
+	log.TWaterHouseIn = ptr(round(waterIn-0.2, 2))
+	log.TRoom = ptr(round(room, 2))
+	log.TRoomTarget = ptr(roomTarget)
+	log.TThermostatSetpoint = ptr(round(supplyTarget, 1))
+	log.OnOffThermostatState = ptr(boolInt(onOff))
+	log.RPM = ptr(math.Round(rpm))
+	log.RPMLimiter = ptr(6000.0)
+	if limiterType == 3 {
+		log.RPMLimiter = ptr(3500.0)
+	}
+	log.RPMLimiterType = ptr(limiterType)
+	log.Fan = ptr(math.Round(fan))
+	log.FanPower = ptr(math.Round(fan * 0.06))
+	log.CMMassPowerIn = ptr(int(math.Round(powerIn)))
+	log.CMMassPowerOut = ptr(int(math.Round(powerOut)))
+	log.CompressorPowerLowAccuracy = ptr(math.Round(rpm * 0.5))
+	log.CentralHeatingFlow = ptr(chPWM)
+	log.DHWFlow = ptr(dhwPWM)
+	log.CentralHeatingPWMRequestedDutyCycle = ptr(chPWM)
+	log.DHWPWMRequestedDutyCycle = ptr(dhwPWM)
+	log.ControlBridgeStatusDecodedWaterPump = ptr(pump)
+	log.ControlBridgeStatusDecodedWaterPump2 = ptr(false)
+	log.ControlBridgeStatusDecodedGasBoiler = ptr(false)
+	log.ControlBridgeStatusDecodedElectricHeater = ptr(heater)
+	log.ControlBridgeStatusDecodedDHWValve = ptr(dhwValve)
+	log.ControlBridgeStatus = ptr(boolInt(pump) | boolInt(heater)<<2 | boolInt(dhwValve)<<4)
+	log.PCompressorIn = ptr(round(pressureIn, 2))
+	log.PCompressorOut = ptr(round(pressureOut, 2))
+	log.PCompressorInTarget = ptr(round(pressureIn, 1))
+	log.TCompressorIn = ptr(round(compressorIn, 2))
+	log.TCompressorOut = ptr(round(compressorOut, 2))
+	log.TCompressorInTransient = ptr(round(compressorIn, 2))
+	log.TCompressorOutTransient = ptr(round(compressorOut, 2))
+	log.DeltaTCompressorInSuperheat = ptr(round(5+p.noise(t, 5), 2))
+	log.TemperatureErrorIntegral = ptr(round((roomTarget-room)*10, 2))
+	log.Valve = ptr(float64(boolInt(dhwValve)) * 100)
+	log.TBoard = ptr(round(28+rpm/500, 1))
+	log.TInverter = ptr(round(30+rpm/250, 1))
+	log.InverterInputVoltage = ptr(round(230+2*p.noise(t, 6), 1))
+	log.SignalStrength = ptr(-70 + int(math.Round(3*p.noise(t, 7))))
+	log.SINR = ptr(12 + int(math.Round(2*p.noise(t, 8))))
+	log.ThermostatStatus = ptr(boolInt(onOff))
+	log.CoolingStatus = ptr(0)
+	if p.HasDHW {
+		log.T1 = ptr(round(tankTop, 2))
+		log.T2 = ptr(round(tankBottom, 2))
+	}
+
+	log.Error = ptr(fault)
+	log.ErrorDecodedDtcNone = ptr(fault == 0)
+	log.ErrorDecodedDtcContinue = ptr(false)
+	log.ErrorDecodedDtcCompressorOff = ptr(fault != 0)
+	log.ErrorDecodedDtcDefrostForbidden = ptr(false)
+	log.ErrorDecodedDtcRequestService = ptr(false)
+	log.ErrorDecodedDtcUseHeatingCurve = ptr(false)
+	log.ErrorDecodedDtcDHWForbidden = ptr(false)
+	log.ErrorDecodedDtcError = ptr(fault != 0)
+	log.ErrorDecodedDtcInactive = ptr(false)
+	return log
+}
+
+// outdoor returns the outside air temperature: a seasonal curve, coldest in
+// mid January, with a daily swing peaking mid afternoon.
+func (p *SimulatedPump) outdoor(t time.Time) float64 {
+	local := t.In(p.location())
+	hour := float64(local.Hour()) + float64(local.Minute())/60
+	day := float64(local.YearDay())
+	seasonal := 10 - 8*math.Cos(2*math.Pi*(day-15)/365)
+	daily := -4 * math.Cos(2*math.Pi*(hour-3)/24)
+	return seasonal + daily + 0.3*p.noise(t, 0)
+}
+
+// mode returns what the pump is doing at t, the heating demand in [0, 1] and
+// how far, from 0 to 1, the current run has progressed.
+func (p *SimulatedPump) mode(t time.Time, outdoor float64) (pumpMode, float64, float64) {
+	local := t.In(p.location())
+	if p.HasDHW {
+		for _, run := range p.dhwRuns(local) {
+			if run.Contains(local) {
+				progress := float64(local.Sub(run.Start)) / float64(run.End.Sub(run.Start))
+				mode := modeDHW
+				if run.legionella {
+					mode = modeLegionella
+				}
+				if outdoor < 3 && progress >= 0.5 && progress < 0.6 {
+					mode = modeDHWDefrost
+				}
+				return mode, 1, progress
+			}
+		}
+	}
+
+	demand := clamp((16-outdoor)/20, 0, 1)
+	if hour := local.Hour(); hour >= 23 || hour < 6 {
+		demand *= 0.6
+	}
+	if demand < 0.05 {
+		return modeStandby, 0, 0
+	}
+
+	offset := time.Duration(p.Seed%uint64(heatingCycle/time.Second)) * time.Second
+	shifted := t.Add(offset)
+	cycle := shifted.Truncate(heatingCycle)
+	on := time.Duration((0.3 + 0.7*demand) * float64(heatingCycle))
+	pos := shifted.Sub(cycle)
+	if pos >= on {
+		return modeStandby, demand, 0
+	}
+	if outdoor < 5 && cycle.Unix()/int64(heatingCycle/time.Second)%2 == 0 && on >= 15*time.Minute && pos >= on-6*time.Minute {
+		return modeHeatingDefrost, demand, float64(pos) / float64(on)
+	}
+	return modeHeating, demand, float64(pos) / float64(on)
+}
+
+type dhwRun struct {
+	Window
+	legionella bool
+}
+
+// dhwRuns lists the tank heating runs on the local day of t and the day before.
+func (p *SimulatedPump) dhwRuns(local time.Time) []dhwRun {
+	var runs []dhwRun
+	for _, days := range []int{-1, 0} {
+		day := time.Date(local.Year(), local.Month(), local.Day()+days, 0, 0, 0, 0, local.Location())
+		at := func(hour, minute int, length time.Duration) Window {
+			start := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+			return Window{Start: start, End: start.Add(length)}
+		}
+		if day.Weekday() == time.Sunday {
+			runs = append(runs, dhwRun{Window: at(2, 0, 40*time.Minute), legionella: true})
+		}
+		runs = append(runs,
+			dhwRun{Window: at(6, 30, 45*time.Minute)},
+			dhwRun{Window: at(19, 0, 30*time.Minute)},
+		)
+	}
+	return runs
+}
+
+// tank returns the DHW tank top and bottom temperatures. They climb during
+// a run and cool slowly afterwards.
+func (p *SimulatedPump) tank(t time.Time, mode pumpMode, progress float64) (float64, float64) {
+	if !p.HasDHW {
+		return 0, 0
+	}
+	if mode == modeDHW || mode == modeLegionella || mode == modeDHWDefrost {
+		target := 52.0
+		if mode == modeLegionella {
+			target = 60
+		}
+		return 40 + (target-40)*progress, 30 + (target-4-30)*progress
+	}
+	local := t.In(p.location())
+	var last time.Time
+	for _, run := range p.dhwRuns(local) {
+		if !run.End.After(local) && run.End.After(last) {
+			last = run.End
+		}
+	}
+	hours := 24.0
+	if !last.IsZero() {
+		hours = local.Sub(last).Hours()
+	}
+	top := clamp(52-0.45*hours, 38, 60)
+	bottom := clamp(48-0.9*hours, 25, 56)
+	return top, bottom
+}
+
+// noise returns a deterministic value in [-1, 1) for the sample at t.
+func (p *SimulatedPump) noise(t time.Time, channel uint64) float64 {
+	x := p.Seed ^ uint64(t.Unix())*0x9e3779b97f4a7c15 ^ channel*0xbf58476d1ce4e5b9
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11)/float64(1<<52) - 1
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}